@@ -0,0 +1,242 @@
+// Package auth authenticates HTTP requests with API keys, Basic auth or,
+// for old clients, a shared secret header.
+//
+// Basic auth checks a slow password hash, so it is meant for people and
+// low-volume clients; give services API keys. Passwords that check out
+// are remembered briefly, but wrong ones cost a full check every time,
+// so rate limit the routes that accept Basic auth.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MethodAPIKey       = "api_key"
+	MethodBasic        = "basic"
+	MethodSharedSecret = "shared_secret"
+)
+
+// LegacyHeader is the shared-password header TerribleSecurityProvider
+// used to check.
+const LegacyHeader = "X-Secret-Password"
+
+// Principal is who a request was authenticated as.
+type Principal struct {
+	Name   string
+	Method string
+	KeyID  string // for MethodAPIKey
+	Scopes []string
+}
+
+// HasScope reports whether p was granted scope, directly or through "*".
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// Authenticator checks each request against the configured credentials.
+// API keys are read from "Authorization: Bearer" or X-API-Key.
+type Authenticator struct {
+	Keys []APIKey
+	// Users are checked for HTTP Basic auth; UserScopes grants them
+	// scopes.
+	Users      Credentials
+	UserScopes map[string][]string
+	Realm      string
+	// LegacyPassword, when set, also accepts requests carrying it in
+	// LegacyHeader. Deprecated: move those clients to API keys.
+	LegacyPassword string
+
+	now        func() time.Time
+	legacyOnce sync.Once
+
+	mu       sync.Mutex
+	verified map[[sha256.Size]byte]time.Time
+}
+
+// Verified Basic credentials are remembered for verifiedTTL, up to
+// maxVerified of them, so a client sending the same password on every
+// request pays for the slow hash once.
+const (
+	verifiedTTL = time.Minute
+	maxVerified = 1024
+)
+
+// checkPassword is CheckPassword, replaceable in tests.
+var checkPassword = CheckPassword
+
+var unauthorizedMsg = []byte("authentication required\n")
+
+// Middleware puts the authenticated Principal into the request context.
+// Requests that fail authentication get a 401 and never reach h.
+func (a *Authenticator) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		p, reason := a.authenticate(rw, req)
+		if p == nil {
+			log.Printf("auth: rejected %s %s: %s", req.Method, req.URL.Path, reason)
+			realm := a.Realm
+			if realm == "" {
+				realm = "restricted"
+			}
+			rw.Header().Add("WWW-Authenticate", `Bearer realm="`+realm+`"`)
+			if a.Users != nil {
+				rw.Header().Add("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+			}
+			rw.WriteHeader(http.StatusUnauthorized)
+			rw.Write(unauthorizedMsg)
+			return
+		}
+		h.ServeHTTP(rw, req.WithContext(ContextWithPrincipal(req.Context(), p)))
+	})
+}
+
+func (a *Authenticator) authenticate(rw http.ResponseWriter, req *http.Request) (*Principal, string) {
+	if key, ok := bearer(req); ok {
+		return a.checkKey(key)
+	}
+	if user, password, ok := req.BasicAuth(); ok {
+		return a.checkBasic(user, password)
+	}
+	if got := req.Header.Get(LegacyHeader); got != "" && a.LegacyPassword != "" {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(a.LegacyPassword)) != 1 {
+			return nil, "wrong shared secret"
+		}
+		a.legacyOnce.Do(func() {
+			log.Printf("auth: %s is deprecated; move its clients to API keys", LegacyHeader)
+		})
+		rw.Header().Set("Deprecation", "true")
+		return &Principal{Name: "legacy", Method: MethodSharedSecret}, ""
+	}
+	return nil, "no credentials"
+}
+
+func bearer(req *http.Request) (string, bool) {
+	if key := req.Header.Get("X-API-Key"); key != "" {
+		return key, true
+	}
+	h := req.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:]), true
+	}
+	return "", false
+}
+
+func (a *Authenticator) checkKey(key string) (*Principal, string) {
+	sum := sha256.Sum256([]byte(key))
+	var match *APIKey
+	// Compare against every key so the time taken does not reveal
+	// which, if any, matched.
+	for i := range a.Keys {
+		want, _ := hex.DecodeString(a.Keys[i].Hash)
+		if subtle.ConstantTimeCompare(sum[:], want) == 1 {
+			match = &a.Keys[i]
+		}
+	}
+	if match == nil {
+		return nil, "unknown api key"
+	}
+	now := time.Now
+	if a.now != nil {
+		now = a.now
+	}
+	if !match.Expires.IsZero() && !now().Before(match.Expires) {
+		return nil, "api key " + match.ID + " expired"
+	}
+	return &Principal{Name: match.Principal, Method: MethodAPIKey, KeyID: match.ID, Scopes: match.Scopes}, ""
+}
+
+func (a *Authenticator) checkBasic(user, password string) (*Principal, string) {
+	hash, ok := a.Users[user]
+	if !ok {
+		// Spend as long as a real check would, so probing for user
+		// names is no faster than probing for passwords.
+		for _, other := range a.Users {
+			CheckPassword(other, password)
+			break
+		}
+		return nil, "unknown user"
+	}
+	if !a.checkCached(user, hash, password) {
+		return nil, "wrong password for " + user
+	}
+	return &Principal{Name: user, Method: MethodBasic, Scopes: a.UserScopes[user]}, ""
+}
+
+// checkCached is CheckPassword with a short memory of successes. The
+// stored hash is part of the cache key, so changing a password in Users
+// takes effect at once.
+func (a *Authenticator) checkCached(user, hash, password string) bool {
+	now := time.Now
+	if a.now != nil {
+		now = a.now
+	}
+	key := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+	a.mu.Lock()
+	expires, ok := a.verified[key]
+	a.mu.Unlock()
+	if ok && now().Before(expires) {
+		return true
+	}
+	if !checkPassword(hash, password) {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.verified) >= maxVerified {
+		for k, exp := range a.verified {
+			if !now().Before(exp) {
+				delete(a.verified, k)
+			}
+		}
+	}
+	if a.verified == nil || len(a.verified) >= maxVerified {
+		a.verified = map[[sha256.Size]byte]time.Time{}
+	}
+	a.verified[key] = now().Add(verifiedTTL)
+	return true
+}
+
+// RequireScope lets requests through only if their Principal has scope.
+// It must run inside Middleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			p, ok := PrincipalFromContext(req.Context())
+			if !ok {
+				rw.WriteHeader(http.StatusUnauthorized)
+				rw.Write(unauthorizedMsg)
+				return
+			}
+			if !p.HasScope(scope) {
+				log.Printf("auth: %s lacks scope %s for %s %s", p.Name, scope, req.Method, req.URL.Path)
+				http.Error(rw, "missing scope "+scope, http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(rw, req)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914, section 11.
+	data := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+			"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, d := range data {
+		t.Run(d.password, func(t *testing.T) {
+			got := hex.EncodeToString(pbkdf2([]byte(d.password), []byte(d.salt), d.iterations, 64))
+			if got != d.want {
+				t.Errorf("expected %s, got %s", d.want, got)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name, body string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	keys, err := LoadAPIKeys(writeFile(t, "keys.json", `[
+		{"id": "k1", "principal": "billing", "hash": "`+HashAPIKey("live-key")+`", "scopes": ["orders:read"]},
+		{"id": "k2", "principal": "reports", "hash": "`+HashAPIKey("old-key")+`", "expires": "2021-01-01T00:00:00Z"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := HashPassword("s3cret", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := LoadCredentials(writeFile(t, "htpasswd", "# users\nfred:"+hash+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Authenticator{
+		Keys:       keys,
+		Users:      users,
+		UserScopes: map[string][]string{"fred": {"*"}},
+		now:        func() time.Time { return time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC) },
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := newTestAuthenticator(t)
+	var got *Principal
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got, _ = PrincipalFromContext(req.Context())
+	})
+
+	data := []struct {
+		name      string
+		legacy    string
+		header    http.Header
+		basic     []string
+		code      int
+		principal string
+		method    string
+	}{
+		{"bearer", "", http.Header{"Authorization": {"Bearer live-key"}}, nil, http.StatusOK, "billing", MethodAPIKey},
+		{"x_api_key", "", http.Header{"X-Api-Key": {"live-key"}}, nil, http.StatusOK, "billing", MethodAPIKey},
+		{"unknown_key", "", http.Header{"Authorization": {"Bearer nope"}}, nil, http.StatusUnauthorized, "", ""},
+		{"expired_key", "", http.Header{"Authorization": {"Bearer old-key"}}, nil, http.StatusUnauthorized, "", ""},
+		{"basic", "", nil, []string{"fred", "s3cret"}, http.StatusOK, "fred", MethodBasic},
+		{"basic_wrong_password", "", nil, []string{"fred", "guess"}, http.StatusUnauthorized, "", ""},
+		{"basic_unknown_user", "", nil, []string{"wilma", "s3cret"}, http.StatusUnauthorized, "", ""},
+		{"legacy_off", "", http.Header{LegacyHeader: {"GOPHER"}}, nil, http.StatusUnauthorized, "", ""},
+		{"legacy_on", "GOPHER", http.Header{LegacyHeader: {"GOPHER"}}, nil, http.StatusOK, "legacy", MethodSharedSecret},
+		{"legacy_wrong", "GOPHER", http.Header{LegacyHeader: {"gopher"}}, nil, http.StatusUnauthorized, "", ""},
+		{"nothing", "", nil, nil, http.StatusUnauthorized, "", ""},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			got = nil
+			a.LegacyPassword = d.legacy
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			for k, v := range d.header {
+				req.Header[k] = v
+			}
+			if d.basic != nil {
+				req.SetBasicAuth(d.basic[0], d.basic[1])
+			}
+			rec := httptest.NewRecorder()
+			a.Middleware(ok).ServeHTTP(rec, req)
+			if rec.Code != d.code {
+				t.Errorf("expected %d, got %d", d.code, rec.Code)
+			}
+			if d.code == http.StatusUnauthorized && !strings.Contains(strings.Join(rec.Header()["Www-Authenticate"], " "), "Basic") {
+				t.Errorf("expected a Basic challenge, got %v", rec.Header())
+			}
+			var name, method string
+			if got != nil {
+				name, method = got.Name, got.Method
+			}
+			if name != d.principal || method != d.method {
+				t.Errorf("expected principal %s/%s, got %s/%s", d.principal, d.method, name, method)
+			}
+		})
+	}
+}
+
+func TestBasicCache(t *testing.T) {
+	a := newTestAuthenticator(t)
+	clock := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return clock }
+	checks := 0
+	checkPassword = func(hash, password string) bool {
+		checks++
+		return CheckPassword(hash, password)
+	}
+	defer func() { checkPassword = CheckPassword }()
+
+	data := []struct {
+		name     string
+		password string
+		advance  time.Duration
+		ok       bool
+		checks   int
+	}{
+		{"first", "s3cret", 0, true, 1},
+		{"cached", "s3cret", 30 * time.Second, true, 1},
+		{"wrong_not_cached", "guess", 0, false, 2},
+		{"wrong_again", "guess", 0, false, 3},
+		{"expired", "s3cret", time.Minute, true, 4},
+	}
+	for _, d := range data {
+		clock = clock.Add(d.advance)
+		if p, _ := a.checkBasic("fred", d.password); (p != nil) != d.ok || checks != d.checks {
+			t.Errorf("%s: expected ok %v after %d checks, got %v after %d", d.name, d.ok, d.checks, p != nil, checks)
+		}
+	}
+	hash, _ := HashPassword("n3w", 1000)
+	a.Users["fred"] = hash
+	if p, _ := a.checkBasic("fred", "s3cret"); p != nil {
+		t.Error("expected a changed password to take effect at once")
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	a := newTestAuthenticator(t)
+	h := a.Middleware(RequireScope("orders:write")(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})))
+	data := []struct {
+		name string
+		set  func(req *http.Request)
+		code int
+	}{
+		{"missing_scope", func(req *http.Request) { req.Header.Set("X-API-Key", "live-key") }, http.StatusForbidden},
+		{"wildcard", func(req *http.Request) { req.SetBasicAuth("fred", "s3cret") }, http.StatusOK},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+			d.set(req)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != d.code {
+				t.Errorf("expected %d, got %d", d.code, rec.Code)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := LoadAPIKeys(writeFile(t, "keys.json", `[{"id": "k", "principal": "p", "hash": "plaintext"}]`)); err == nil {
+		t.Error("expected an error for a key stored in plain text")
+	}
+	if _, err := LoadCredentials(writeFile(t, "htpasswd", "fred:s3cret\n")); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("expected an error naming line 1, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIKey is one entry of an API key file. Only the SHA-256 of the key is
+// stored; keys are random enough that a salt adds nothing.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Principal string    `json:"principal"`
+	Hash      string    `json:"hash"`
+	Scopes    []string  `json:"scopes"`
+	Expires   time.Time `json:"expires"` // zero means never
+}
+
+// HashAPIKey returns the value to store in APIKey.Hash for key.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadAPIKeys reads a JSON array of APIKey.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for i, k := range keys {
+		if k.ID == "" || k.Principal == "" {
+			return nil, fmt.Errorf("%s: key %d needs an id and a principal", path, i)
+		}
+		if b, err := hex.DecodeString(k.Hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("%s: key %q: hash must be a hex SHA-256", path, k.ID)
+		}
+	}
+	return keys, nil
+}
+
+// Credentials maps Basic auth user names to password hashes made by
+// HashPassword.
+type Credentials map[string]string
+
+// LoadCredentials reads an htpasswd-style file of user:hash lines.
+// Blank lines and lines starting with # are skipped.
+func LoadCredentials(path string) (Credentials, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	creds := Credentials{}
+	s := bufio.NewScanner(f)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		user, hash := split2(text, ":")
+		if user == "" {
+			return nil, fmt.Errorf("%s:%d: expected user:hash", path, line)
+		}
+		if _, _, _, err := parsePasswordHash(hash); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		creds[user] = hash
+	}
+	return creds, s.Err()
+}
+
+func split2(s, sep string) (string, string) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return "", ""
+	}
+	return s[:i], s[i+len(sep):]
+}
+
+const (
+	hashScheme = "pbkdf2-sha256"
+	// DefaultIterations follows the OWASP recommendation for
+	// PBKDF2-HMAC-SHA256.
+	DefaultIterations = 600000
+)
+
+// HashPassword returns a salted hash of password in the form
+// pbkdf2-sha256$iterations$salt$hash, with base64 salt and hash.
+func HashPassword(password string, iterations int) (string, error) {
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(password), salt, iterations, sha256.Size)
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("%s$%d$%s$%s", hashScheme, iterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+var errPasswordHash = errors.New("password hash must look like pbkdf2-sha256$iterations$salt$hash")
+
+func parsePasswordHash(s string) (iterations int, salt, key []byte, err error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return 0, nil, nil, errPasswordHash
+	}
+	iterations, err = strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return 0, nil, nil, errPasswordHash
+	}
+	enc := base64.RawStdEncoding
+	if salt, err = enc.DecodeString(parts[2]); err != nil {
+		return 0, nil, nil, errPasswordHash
+	}
+	if key, err = enc.DecodeString(parts[3]); err != nil || len(key) == 0 {
+		return 0, nil, nil, errPasswordHash
+	}
+	return iterations, salt, key, nil
+}
+
+// CheckPassword reports whether password matches a hash from
+// HashPassword, in time that does not depend on how much of it matches.
+func CheckPassword(hash, password string) bool {
+	iterations, salt, key, err := parsePasswordHash(hash)
+	if err != nil {
+		return false
+	}
+	got := pbkdf2([]byte(password), salt, iterations, len(key))
+	return subtle.ConstantTimeCompare(got, key) == 1
+}
+
+// pbkdf2 implements PBKDF2 (RFC 8018) with HMAC-SHA256.
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var out []byte
+	var block [4]byte
+	for i := uint32(1); len(out) < keyLen; i++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block[:], i)
+		prf.Write(block[:])
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
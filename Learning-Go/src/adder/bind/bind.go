@@ -0,0 +1,169 @@
+// Package bind decodes JSON request bodies strictly and reports every
+// problem with them at once, as RFC 7807 problem details.
+package bind
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Problem is an RFC 7807 problem details response.
+type Problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is one bad field, named by its path from the top of the
+// body, such as items[2].id.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func newProblem(status int, detail string) *Problem {
+	return &Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+func (p *Problem) Error() string {
+	if len(p.Errors) == 0 {
+		return p.Detail
+	}
+	msgs := make([]string, len(p.Errors))
+	for i, fe := range p.Errors {
+		msgs[i] = fe.Path + " " + fe.Message
+	}
+	return p.Detail + ": " + strings.Join(msgs, "; ")
+}
+
+// Write sends p as application/problem+json.
+func (p *Problem) Write(rw http.ResponseWriter) {
+	rw.Header().Set("Content-Type", "application/problem+json")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(p.Status)
+	json.NewEncoder(rw).Encode(p)
+}
+
+type Config struct {
+	// MaxBytes caps the request body. Zero means 1 MB.
+	MaxBytes int64
+}
+
+// Decode reads a single JSON value from req's body into v, which must be
+// a pointer. The request must say it is JSON, the body must fit in
+// cfg.MaxBytes, and it may not contain fields v doesn't have or
+// anything after the value. Field names must match their json tags
+// exactly.
+//
+// Fields are checked against their validate tags, a comma-separated list
+// of rules:
+//
+//	required  present, not null and, for strings, arrays and objects, not empty
+//	min=N     numbers at least N; strings, arrays and objects at least N long
+//	max=N     numbers at most N; strings, arrays and objects at most N long
+//
+// A body the client got wrong comes back as a *Problem listing every bad
+// field, and v is left alone. Any other error is the server's fault.
+func Decode(rw http.ResponseWriter, req *http.Request, v interface{}, cfg Config) error {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 20
+	}
+	if t := reflect.TypeOf(v); t == nil || t.Kind() != reflect.Ptr {
+		return fmt.Errorf("bind: Decode needs a pointer, not %T", v)
+	}
+	if !isJSON(req.Header.Get("Content-Type")) {
+		return newProblem(http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, req.Body, cfg.MaxBytes))
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		return newProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", cfg.MaxBytes))
+	}
+	if err != nil {
+		return newProblem(http.StatusBadRequest, "reading body: "+err.Error())
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return syntaxProblem(err, body)
+	}
+	end := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		return newProblem(http.StatusBadRequest, fmt.Sprintf("body has data after the JSON value, which ends at byte %d", end))
+	}
+
+	c := &checker{}
+	if err := c.check("", raw, reflect.TypeOf(v).Elem()); err != nil {
+		return err
+	}
+	if len(c.errs) == 1 && c.errs[0].Path == "" {
+		return newProblem(http.StatusBadRequest, "body "+c.errs[0].Message)
+	}
+	if len(c.errs) > 0 {
+		p := newProblem(http.StatusBadRequest, fmt.Sprintf("%d invalid field(s)", len(c.errs)))
+		p.Errors = c.errs
+		return p
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		// The checker has already vetted the body, so only a mismatch it
+		// missed is the client's; anything else, such as a nil pointer for
+		// v, is ours.
+		var typeErr *json.UnmarshalTypeError
+		var syntax *json.SyntaxError
+		if errors.As(err, &typeErr) || errors.As(err, &syntax) {
+			return newProblem(http.StatusBadRequest, err.Error())
+		}
+		return fmt.Errorf("bind: %v", err)
+	}
+	return nil
+}
+
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+func syntaxProblem(err error, body []byte) *Problem {
+	var syntax *json.SyntaxError
+	switch {
+	case err == io.EOF:
+		return newProblem(http.StatusBadRequest, "body must not be empty")
+	case err == io.ErrUnexpectedEOF:
+		return newProblem(http.StatusBadRequest, fmt.Sprintf("body ends in the middle of a JSON value at byte %d", len(body)))
+	case errors.As(err, &syntax):
+		return newProblem(http.StatusBadRequest, fmt.Sprintf("body is not valid JSON at byte %d: %v", syntax.Offset, err))
+	}
+	return newProblem(http.StatusBadRequest, "body is not valid JSON: "+err.Error())
+}
+
+// Bind is Decode with the default Config that answers the request itself
+// when it fails, returning false so the handler can just return.
+func Bind(rw http.ResponseWriter, req *http.Request, v interface{}) bool {
+	err := Decode(rw, req, v, Config{})
+	if err == nil {
+		return true
+	}
+	var p *Problem
+	if !errors.As(err, &p) {
+		log.Printf("bind: %s %s: %v", req.Method, req.URL.Path, err)
+		p = newProblem(http.StatusInternalServerError, "")
+	}
+	p.Write(rw)
+	return false
+}
@@ -0,0 +1,183 @@
+package bind
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/adder/jsontime"
+)
+
+type item struct {
+	ID   string `json:"id" validate:"required"`
+	Name string `json:"name" validate:"required,max=10"`
+	Qty  int8   `json:"qty" validate:"min=1,max=100"`
+}
+
+type audit struct {
+	Source string `json:"source"`
+}
+
+type order struct {
+	audit
+	ID          string            `json:"id" validate:"required"`
+	DateOrdered jsontime.Time     `json:"date_ordered" validate:"required"`
+	CustomerID  string            `json:"customer_id" validate:"required,min=3"`
+	Items       []item            `json:"items" validate:"required,max=2"`
+	Rush        bool              `json:"rush"`
+	Discount    *float64          `json:"discount,omitempty" validate:"min=0,max=0.5"`
+	Tags        map[string]string `json:"tags"`
+	Count       int               `json:"count,string"`
+	Secret      string            `json:"-"`
+}
+
+const valid = `{"id":"o1","date_ordered":"2020-05-01","customer_id":"c01",` +
+	`"items":[{"id":"i1","name":"pen","qty":2}],"source":"web","discount":0.1,"tags":{"a":"b"},"count":"3"}`
+
+func TestBind(t *testing.T) {
+	data := []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+		detail      string
+		errors      []string
+	}{
+		{name: "valid", contentType: "application/json", body: valid, code: http.StatusOK},
+		{name: "vendor_type", contentType: "application/vnd.orders+json; charset=utf-8", body: valid, code: http.StatusOK},
+		{name: "wrong_type", contentType: "text/plain", body: valid, code: http.StatusUnsupportedMediaType,
+			detail: "Content-Type must be application/json"},
+		{name: "no_type", body: valid, code: http.StatusUnsupportedMediaType, detail: "Content-Type must be application/json"},
+		{name: "too_large", contentType: "application/json", body: `{"id":"` + strings.Repeat("x", 300) + `"}`,
+			code: http.StatusRequestEntityTooLarge, detail: "body must not be larger than 256 bytes"},
+		{name: "empty", contentType: "application/json", body: "  ", code: http.StatusBadRequest, detail: "body must not be empty"},
+		{name: "truncated", contentType: "application/json", body: `{"id":"o1",`, code: http.StatusBadRequest,
+			detail: "body ends in the middle of a JSON value at byte 11"},
+		{name: "syntax", contentType: "application/json", body: `{"id" "o1"}`, code: http.StatusBadRequest,
+			detail: "body is not valid JSON at byte 7: invalid character '\"' after object key"},
+		{name: "trailing_value", contentType: "application/json", body: valid + ` {}`, code: http.StatusBadRequest,
+			detail: "body has data after the JSON value, which ends at byte 161"},
+		{name: "trailing_garbage", contentType: "application/json", body: valid + `]`, code: http.StatusBadRequest,
+			detail: "body has data after the JSON value, which ends at byte 161"},
+		{name: "trailing_space", contentType: "application/json", body: valid + "\n\n", code: http.StatusOK},
+		{name: "not_object", contentType: "application/json", body: `[1,2]`, code: http.StatusBadRequest,
+			detail: "body must be an object"},
+		{name: "every_field", contentType: "application/json", code: http.StatusBadRequest,
+			body: `{"id":"","date_ordered":"May 1st","customer_id":"c1","rush":"yes","discount":0.9,` +
+				`"items":[{"id":"i1","name":"a very long name","qty":0},{"name":"ink","qty":300},{"id":"i3","name":"x","qty":1.5}],` +
+				`"tags":{"a":1},"count":3,"Source":"web","extra":true}`,
+			detail: "15 invalid field(s)",
+			errors: []string{
+				"id is required",
+				`date_ordered is invalid: jsontime: "May 1st" matches none of ` + strings.Join(jsontime.Default.Accept, ", "),
+				"customer_id must be at least 3 characters long",
+				"items[0].name must be at most 10 characters long",
+				"items[0].qty must be at least 1",
+				"items[1].id is required",
+				"items[1].qty is out of range",
+				"items[2].qty must be a whole number",
+				"items must have at most 2 entries",
+				"rush must be true or false",
+				"discount must be at most 0.5",
+				"tags.a must be a string",
+				"count must be a quoted int",
+				"Source is not a known field",
+				"extra is not a known field",
+			}},
+		{name: "missing", contentType: "application/json", body: `{"items":[],"discount":null}`, code: http.StatusBadRequest,
+			detail: "4 invalid field(s)",
+			errors: []string{"id is required", "date_ordered is required", "customer_id is required", "items is required"}},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			var got order
+			h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				err := Decode(rw, req, &got, Config{MaxBytes: 256})
+				if err == nil {
+					return
+				}
+				err.(*Problem).Write(rw)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(d.body))
+			if d.contentType != "" {
+				req.Header.Set("Content-Type", d.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != d.code {
+				t.Fatalf("expected %d, got %d: %s", d.code, rec.Code, rec.Body.String())
+			}
+			if d.code == http.StatusOK {
+				if got.ID != "o1" || got.Items[0].Qty != 2 || got.Source != "web" || *got.Discount != 0.1 || got.Count != 3 {
+					t.Errorf("expected the order to be decoded, got %+v", got)
+				}
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("expected application/problem+json, got %s", ct)
+			}
+			var p Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Status != d.code || p.Title != http.StatusText(d.code) || p.Type != "about:blank" {
+				t.Errorf("expected status %d and its title, got %+v", d.code, p)
+			}
+			if p.Detail != d.detail {
+				t.Errorf("expected detail `%s`, got `%s`", d.detail, p.Detail)
+			}
+			var errs []string
+			for _, fe := range p.Errors {
+				errs = append(errs, fe.Path+" "+fe.Message)
+			}
+			if strings.Join(errs, "\n") != strings.Join(d.errors, "\n") {
+				t.Errorf("expected errors:\n%s\ngot:\n%s", strings.Join(d.errors, "\n"), strings.Join(errs, "\n"))
+			}
+			if got.ID != "" {
+				t.Errorf("expected the order to be left alone, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestBindAnswersRequest(t *testing.T) {
+	var ran bool
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var o order
+		if !Bind(rw, req, &o) {
+			return
+		}
+		ran = true
+	})
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"nope":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if ran || rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a 400 without reaching the handler, got %d", rec.Code)
+	}
+}
+
+func TestBadTag(t *testing.T) {
+	var v struct {
+		N int `json:"n" validate:"min=one"`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"n":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	if Bind(rec, req, &v) || rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected a malformed tag to be a 500, got %d", rec.Code)
+	}
+}
+
+func TestNilPointer(t *testing.T) {
+	var o *order
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(valid))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	if Bind(rec, req, o) || rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected a nil pointer to be a 500, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
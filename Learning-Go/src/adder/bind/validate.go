@@ -0,0 +1,366 @@
+package bind
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// checker walks a decoded JSON value alongside the Go type it is meant
+// for, collecting everything wrong with it rather than stopping at the
+// first problem the way encoding/json does.
+type checker struct {
+	errs []FieldError
+}
+
+func (c *checker) add(path, format string, args ...interface{}) {
+	c.errs = append(c.errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// check reports problems with raw as a t at path. It returns an error
+// only for a malformed validate tag.
+func (c *checker) check(path string, raw interface{}, t reflect.Type) error {
+	if raw == nil {
+		// encoding/json leaves the field alone; required catches it
+		// where it matters.
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshaler) || reflect.PtrTo(t).Implements(textUnmarshaler) {
+		c.probe(path, raw, t)
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil
+	case reflect.String:
+		if _, ok := raw.(string); !ok {
+			c.add(path, "must be a string")
+		}
+	case reflect.Bool:
+		if _, ok := raw.(bool); !ok {
+			c.add(path, "must be true or false")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := raw.(json.Number)
+		if !ok {
+			c.add(path, "must be a number")
+			break
+		}
+		i, err := n.Int64()
+		if err != nil {
+			c.add(path, "must be a whole number")
+		} else if reflect.Zero(t).OverflowInt(i) {
+			c.add(path, "is out of range")
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := raw.(json.Number)
+		if !ok {
+			c.add(path, "must be a number")
+			break
+		}
+		u, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			c.add(path, "must be a whole number, zero or more")
+		} else if reflect.Zero(t).OverflowUint(u) {
+			c.add(path, "is out of range")
+		}
+	case reflect.Float32, reflect.Float64:
+		n, ok := raw.(json.Number)
+		if !ok {
+			c.add(path, "must be a number")
+			break
+		}
+		f, err := n.Float64()
+		if err != nil || reflect.Zero(t).OverflowFloat(f) {
+			c.add(path, "is out of range")
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := raw.([]interface{})
+		if !ok {
+			if s, isString := raw.(string); isString && t.Elem().Kind() == reflect.Uint8 {
+				// []byte is sent as base64.
+				c.probe(path, s, t)
+				break
+			}
+			c.add(path, "must be an array")
+			break
+		}
+		if t.Kind() == reflect.Array && len(arr) > t.Len() {
+			c.add(path, "must have at most %d entries", t.Len())
+		}
+		for i, elem := range arr {
+			if err := c.check(fmt.Sprintf("%s[%d]", path, i), elem, t.Elem()); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			c.add(path, "must be an object")
+			break
+		}
+		if t.Key().Kind() != reflect.String {
+			c.probe(path, raw, t)
+			break
+		}
+		for _, k := range sortedKeys(obj) {
+			if err := c.check(join(path, k), obj[k], t.Elem()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			c.add(path, "must be an object")
+			break
+		}
+		return c.checkStruct(path, obj, t)
+	default:
+		c.probe(path, raw, t)
+	}
+	return nil
+}
+
+func (c *checker) checkStruct(path string, obj map[string]interface{}, t reflect.Type) error {
+	fields := structFields(t)
+	known := map[string]bool{}
+	for _, f := range fields {
+		known[f.name] = true
+		r, err := parseRules(f.tag)
+		if err != nil {
+			return fmt.Errorf("bind: field %s of %v: %v", f.name, t, err)
+		}
+		fpath := join(path, f.name)
+		raw, present := obj[f.name]
+		if r.required && (!present || isEmpty(raw)) {
+			c.add(fpath, "is required")
+			continue
+		}
+		if !present || raw == nil {
+			continue
+		}
+		before := len(c.errs)
+		if f.quoted {
+			c.probeQuoted(fpath, raw, f.typ)
+		} else if err := c.check(fpath, raw, f.typ); err != nil {
+			return err
+		}
+		// Bad entries don't stop an array or object being too long, but
+		// a number of the wrong type can't be compared.
+		switch raw.(type) {
+		case []interface{}, map[string]interface{}:
+			c.checkRange(fpath, raw, r)
+		default:
+			if len(c.errs) == before {
+				c.checkRange(fpath, raw, r)
+			}
+		}
+	}
+	for _, k := range sortedKeys(obj) {
+		if !known[k] {
+			c.add(join(path, k), "is not a known field")
+		}
+	}
+	return nil
+}
+
+// probe decodes raw into a new t with encoding/json, for types that
+// decode themselves.
+func (c *checker) probe(path string, raw interface{}, t reflect.Type) {
+	b, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(b, reflect.New(t).Interface())
+	}
+	if err != nil {
+		c.add(path, "is invalid: %v", err)
+	}
+}
+
+// probeQuoted is probe for a field tagged ,string, which encoding/json
+// only honours on struct fields.
+func (c *checker) probeQuoted(path string, raw interface{}, t reflect.Type) {
+	wrapper := reflect.StructOf([]reflect.StructField{{Name: "V", Type: t, Tag: `json:"v,string"`}})
+	b, err := json.Marshal(map[string]interface{}{"v": raw})
+	if err == nil {
+		err = json.Unmarshal(b, reflect.New(wrapper).Interface())
+	}
+	if err != nil {
+		c.add(path, "must be a quoted %v", t)
+	}
+}
+
+func (c *checker) checkRange(path string, raw interface{}, r rules) {
+	if r.min == nil && r.max == nil {
+		return
+	}
+	var size float64
+	var unit string
+	switch v := raw.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return
+		}
+		size = f
+	case string:
+		size, unit = float64(utf8.RuneCountInString(v)), " characters long"
+	case []interface{}:
+		size, unit = float64(len(v)), " entries"
+	case map[string]interface{}:
+		size, unit = float64(len(v)), " entries"
+	default:
+		return
+	}
+	verb := "be"
+	if unit == " entries" {
+		verb = "have"
+	}
+	if r.min != nil && size < *r.min {
+		c.add(path, "must %s at least %s%s", verb, formatFloat(*r.min), unit)
+	}
+	if r.max != nil && size > *r.max {
+		c.add(path, "must %s at most %s%s", verb, formatFloat(*r.max), unit)
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func isEmpty(raw interface{}) bool {
+	switch v := raw.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+type rules struct {
+	required bool
+	min, max *float64
+}
+
+func parseRules(tag string) (rules, error) {
+	var r rules
+	if tag == "" {
+		return r, nil
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		switch name {
+		case "required":
+			r.required = true
+		case "min", "max":
+			f, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return r, fmt.Errorf("bad %s rule %q", name, rule)
+			}
+			if name == "min" {
+				r.min = &f
+			} else {
+				r.max = &f
+			}
+		default:
+			return r, fmt.Errorf("unknown rule %q", rule)
+		}
+	}
+	return r, nil
+}
+
+type field struct {
+	name   string
+	typ    reflect.Type
+	tag    string
+	quoted bool
+}
+
+// structFields lists the JSON fields of t as encoding/json sees them,
+// including those promoted from embedded structs without a json name.
+// A field hides any of the same name in structs embedded below it.
+func structFields(t reflect.Type) []field {
+	var out []field
+	seen := map[string]bool{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		var embedded []reflect.Type
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if i := strings.Index(tag, ","); i >= 0 {
+				name, opts = tag[:i], tag[i+1:]
+			}
+			ft := sf.Type
+			if sf.Anonymous && name == "" {
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					embedded = append(embedded, ft)
+					continue
+				}
+			}
+			if sf.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			quoted := false
+			for _, o := range strings.Split(opts, ",") {
+				quoted = quoted || o == "string"
+			}
+			out = append(out, field{name: name, typ: sf.Type, tag: sf.Tag.Get("validate"), quoted: quoted})
+		}
+		for _, et := range embedded {
+			walk(et)
+		}
+	}
+	walk(t)
+	return out
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,204 @@
+// Package compress gzips and deflates HTTP responses for clients that
+// ask for it, and inflates gzipped request bodies.
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+)
+
+// DefaultContentTypes are the media types compressed when
+// Config.ContentTypes is empty. An entry ending in / matches the whole
+// type, and one starting with + matches that structured syntax suffix.
+var DefaultContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/x-ndjson",
+	"application/javascript",
+	"application/xml",
+	"application/problem+json",
+	"image/svg+xml",
+	"+json",
+	"+xml",
+}
+
+type Config struct {
+	// MinSize is the smallest response body worth compressing. Zero
+	// means 1 KB.
+	MinSize int
+	// Level is the gzip and zlib compression level. Zero means
+	// gzip.DefaultCompression.
+	Level int
+	// ContentTypes lists what to compress. Empty means
+	// DefaultContentTypes.
+	ContentTypes []string
+	// MaxRequestBytes caps a request body after it is inflated, so a
+	// small upload can't expand into gigabytes. Zero means 10 MB.
+	MaxRequestBytes int64
+}
+
+type compressor struct {
+	cfg      Config
+	gzipPool sync.Pool
+	zlibPool sync.Pool
+}
+
+// Middleware compresses responses according to cfg. A HEAD response gets
+// the headers the matching GET would, judged by what the handler writes
+// or else its Content-Length, and no body. It panics if cfg.Level is out
+// of range.
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1 << 10
+	}
+	if cfg.Level == 0 {
+		cfg.Level = gzip.DefaultCompression
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultContentTypes
+	}
+	if cfg.MaxRequestBytes <= 0 {
+		cfg.MaxRequestBytes = 10 << 20
+	}
+	if _, err := gzip.NewWriterLevel(nil, cfg.Level); err != nil {
+		panic(fmt.Sprintf("compress: %v", err))
+	}
+	c := &compressor{cfg: cfg}
+	c.gzipPool.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, cfg.Level)
+		return w
+	}
+	c.zlibPool.New = func() interface{} {
+		w, _ := zlib.NewWriterLevel(nil, cfg.Level)
+		return w
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if !c.inflateRequest(rw, req) {
+				return
+			}
+			if req.Header.Get("Range") != "" {
+				h.ServeHTTP(rw, req)
+				return
+			}
+			cw := &compressWriter{
+				ResponseWriter: rw,
+				c:              c,
+				encoding:       Negotiate(req.Header.Get("Accept-Encoding")),
+				head:           req.Method == http.MethodHead,
+			}
+			defer cw.close()
+			h.ServeHTTP(wrap(cw), req)
+		})
+	}
+}
+
+// inflateRequest swaps a gzipped request body for one that inflates it.
+// It answers 400 itself and returns false if the body isn't gzip.
+func (c *compressor) inflateRequest(rw http.ResponseWriter, req *http.Request) bool {
+	switch strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding"))) {
+	case Gzip, "x-gzip":
+	default:
+		return true
+	}
+	zr, err := gzip.NewReader(req.Body)
+	if err != nil {
+		http.Error(rw, "request body is not valid gzip", http.StatusBadRequest)
+		return false
+	}
+	req.Body = http.MaxBytesReader(rw, &gzipBody{zr, req.Body}, c.cfg.MaxRequestBytes)
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	return true
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	orig io.Closer
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.orig.Close()
+}
+
+// Negotiate picks gzip or deflate from an Accept-Encoding header, going
+// by q-values and preferring gzip on a tie. It returns "" if neither is
+// acceptable.
+func Negotiate(acceptEncoding string) string {
+	q := map[string]float64{}
+	star, hasStar := 0.0, false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			w, err := strconv.ParseFloat(param[2:], 64)
+			if err != nil || w < 0 || w > 1 {
+				w = 0
+			}
+			weight = w
+		}
+		if name == "x-gzip" {
+			name = Gzip
+		}
+		if name == "*" {
+			star, hasStar = weight, true
+			continue
+		}
+		q[name] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range []string{Gzip, Deflate} {
+		w, ok := q[enc]
+		if !ok && hasStar {
+			w, ok = star, true
+		}
+		if ok && w > bestQ {
+			best, bestQ = enc, w
+		}
+	}
+	return best
+}
+
+// compressible reports whether contentType matches one of types.
+func compressible(contentType string, types []string) bool {
+	mt := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if mt == "" {
+		return false
+	}
+	for _, t := range types {
+		switch {
+		case strings.HasPrefix(t, "+"):
+			if strings.HasSuffix(mt, t) {
+				return true
+			}
+		case strings.HasSuffix(t, "/"):
+			if strings.HasPrefix(mt, t) {
+				return true
+			}
+		case mt == t:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	data := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip, deflate, br", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"GZIP; q=0.8 , deflate;q=0.2", "gzip"},
+		{"gzip;q=0", ""},
+		{"*", "gzip"},
+		{"*;q=0.5, gzip;q=0", "deflate"},
+		{"identity", ""},
+		{"br;q=1.0, x-gzip;q=0.3", "gzip"},
+		{"gzip;q=nonsense", ""},
+	}
+	for _, d := range data {
+		t.Run(d.header, func(t *testing.T) {
+			if got := Negotiate(d.header); got != d.want {
+				t.Errorf("expected `%s`, got `%s`", d.want, got)
+			}
+		})
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader = bytes.NewReader(body)
+	var err error
+	switch encoding {
+	case Gzip:
+		r, err = gzip.NewReader(r)
+	case Deflate:
+		r, err = zlib.NewReader(r)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestMiddleware(t *testing.T) {
+	big := `{"items":"` + strings.Repeat("pen ", 400) + `"}`
+	data := []struct {
+		name        string
+		accept      string
+		reqHeader   http.Header
+		respHeader  http.Header
+		status      int
+		body        string
+		wantEnc     string
+		wantVary    bool
+		wantHeaders http.Header
+	}{
+		{name: "gzip", accept: "gzip, deflate", respHeader: http.Header{"Content-Type": {"application/json"}, "Content-Length": {"1612"}},
+			body: big, wantEnc: "gzip", wantVary: true, wantHeaders: http.Header{"Content-Length": nil}},
+		{name: "deflate", accept: "gzip;q=0.1, deflate", respHeader: http.Header{"Content-Type": {"application/json"}},
+			body: big, wantEnc: "deflate", wantVary: true},
+		{name: "not_accepted", accept: "identity", respHeader: http.Header{"Content-Type": {"application/json"}},
+			body: big, wantVary: true},
+		{name: "too_small", accept: "gzip", respHeader: http.Header{"Content-Type": {"application/json"}},
+			body: `{"ok":true}`, wantVary: true},
+		{name: "suffix_type", accept: "gzip", respHeader: http.Header{"Content-Type": {"application/vnd.orders+json; charset=utf-8"}},
+			body: big, wantEnc: "gzip", wantVary: true},
+		{name: "sniffed", accept: "gzip", body: "<html>" + big, wantEnc: "gzip", wantVary: true,
+			wantHeaders: http.Header{"Content-Type": {"text/html; charset=utf-8"}}},
+		{name: "incompressible", accept: "gzip", respHeader: http.Header{"Content-Type": {"image/png"}}, body: big},
+		{name: "already_encoded", accept: "gzip", respHeader: http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"br"}},
+			body: big, wantEnc: "br"},
+		{name: "range", accept: "gzip", reqHeader: http.Header{"Range": {"bytes=0-99"}},
+			respHeader: http.Header{"Content-Type": {"application/json"}}, body: big},
+		{name: "not_modified", accept: "gzip", respHeader: http.Header{"Content-Type": {"application/json"}}, status: http.StatusNotModified},
+		{name: "weak_etag", accept: "gzip", respHeader: http.Header{"Content-Type": {"text/plain"}, "Etag": {`"v1"`}},
+			body: big, wantEnc: "gzip", wantVary: true, wantHeaders: http.Header{"Etag": {`W/"v1"`}}},
+		{name: "existing_vary", accept: "gzip", respHeader: http.Header{"Content-Type": {"text/plain"}, "Vary": {"Origin, accept-encoding"}},
+			body: big, wantEnc: "gzip", wantHeaders: http.Header{"Vary": {"Origin, accept-encoding"}}},
+	}
+	mw := Middleware(Config{})
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			h := mw(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				for k, v := range d.respHeader {
+					rw.Header()[k] = v
+				}
+				if d.status != 0 {
+					rw.WriteHeader(d.status)
+				}
+				// Write in small pieces to exercise the holding buffer.
+				for s := d.body; s != ""; {
+					n := 100
+					if n > len(s) {
+						n = len(s)
+					}
+					io.WriteString(rw, s[:n])
+					s = s[n:]
+				}
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set("Accept-Encoding", d.accept)
+			for k, v := range d.reqHeader {
+				req.Header[k] = v
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != d.wantEnc {
+				t.Errorf("expected Content-Encoding `%s`, got `%s`", d.wantEnc, got)
+			}
+			if got := rec.Header().Get("Vary") == "Accept-Encoding"; got != d.wantVary {
+				t.Errorf("expected Vary Accept-Encoding %v, got %v", d.wantVary, rec.Header()["Vary"])
+			}
+			for k, v := range d.wantHeaders {
+				if strings.Join(rec.Header()[k], ",") != strings.Join(v, ",") {
+					t.Errorf("expected %s %v, got %v", k, v, rec.Header()[k])
+				}
+			}
+			if d.wantEnc == "br" {
+				return
+			}
+			if got := decode(t, d.wantEnc, rec.Body.Bytes()); got != d.body {
+				t.Errorf("expected the body back, got %d bytes: %.40q", len(got), got)
+			}
+		})
+	}
+}
+
+func TestHead(t *testing.T) {
+	big := strings.Repeat("pen ", 400)
+	data := []struct {
+		name       string
+		respHeader http.Header
+		body       string
+		wantEnc    string
+		wantVary   bool
+		wantLength string
+	}{
+		{name: "writes_body", respHeader: http.Header{"Content-Type": {"text/plain"}}, body: big, wantEnc: "gzip", wantVary: true},
+		{name: "length_only", respHeader: http.Header{"Content-Type": {"text/plain"}, "Content-Length": {"1600"}},
+			wantEnc: "gzip", wantVary: true},
+		{name: "small", respHeader: http.Header{"Content-Type": {"text/plain"}, "Content-Length": {"11"}},
+			wantVary: true, wantLength: "11"},
+		{name: "incompressible", respHeader: http.Header{"Content-Type": {"image/png"}, "Content-Length": {"1600"}},
+			wantLength: "1600"},
+	}
+	mw := Middleware(Config{})
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			h := mw(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				for k, v := range d.respHeader {
+					rw.Header()[k] = v
+				}
+				io.WriteString(rw, d.body)
+			}))
+			req := httptest.NewRequest(http.MethodHead, "/orders", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != d.wantEnc {
+				t.Errorf("expected Content-Encoding `%s`, got `%s`", d.wantEnc, got)
+			}
+			if got := rec.Header().Get("Vary") == "Accept-Encoding"; got != d.wantVary {
+				t.Errorf("expected Vary Accept-Encoding %v, got %v", d.wantVary, rec.Header()["Vary"])
+			}
+			if got := rec.Header().Get("Content-Length"); got != d.wantLength {
+				t.Errorf("expected Content-Length `%s`, got `%s`", d.wantLength, got)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("expected no body, got %d bytes", rec.Body.Len())
+			}
+		})
+	}
+}
+
+func TestFlushCompressesSmallResponses(t *testing.T) {
+	h := Middleware(Config{})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(rw, "data: 1\n\n")
+		rw.(http.Flusher).Flush()
+		io.WriteString(rw, "data: 2\n\n")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if !rec.Flushed {
+		t.Error("expected the flush to reach the recorder")
+	}
+	if rec.Header().Get("Content-Encoding") != Gzip {
+		t.Errorf("expected a flushed stream to be compressed, got %v", rec.Header())
+	}
+	if got := decode(t, Gzip, rec.Body.Bytes()); got != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("expected both events, got %q", got)
+	}
+}
+
+func TestWriterKeepsInterfaces(t *testing.T) {
+	h := Middleware(Config{})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, ok := rw.(http.Hijacker); ok {
+			t.Error("expected no Hijacker, since the recorder isn't one")
+		}
+		if _, ok := rw.(http.Flusher); !ok {
+			t.Error("expected a Flusher")
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func gzipped(s string) []byte {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	io.WriteString(zw, s)
+	zw.Close()
+	return b.Bytes()
+}
+
+func TestRequestBody(t *testing.T) {
+	data := []struct {
+		name     string
+		encoding string
+		body     []byte
+		code     int
+		want     string
+	}{
+		{"gzip", "gzip", gzipped(`{"id":"o1"}`), http.StatusOK, `{"id":"o1"}`},
+		{"x_gzip", "x-gzip", gzipped(`{"id":"o1"}`), http.StatusOK, `{"id":"o1"}`},
+		{"plain", "", []byte(`{"id":"o1"}`), http.StatusOK, `{"id":"o1"}`},
+		{"not_gzip", "gzip", []byte(`{"id":"o1"}`), http.StatusBadRequest, "request body is not valid gzip\n"},
+		{"bomb", "gzip", gzipped(strings.Repeat("0", 2000)), http.StatusRequestEntityTooLarge, "too large"},
+	}
+	h := Middleware(Config{MaxRequestBytes: 1000})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Encoding") != "" {
+			t.Error("expected Content-Encoding to be removed")
+		}
+		b, err := ioutil.ReadAll(req.Body)
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(rw, "too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		rw.Write(b)
+	}))
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(d.body))
+			if d.encoding != "" {
+				req.Header.Set("Content-Encoding", d.encoding)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != d.code {
+				t.Errorf("expected %d, got %d", d.code, rec.Code)
+			}
+			if got := strings.TrimSuffix(rec.Body.String(), "\n"); got != strings.TrimSuffix(d.want, "\n") {
+				t.Errorf("expected `%s`, got `%s`", d.want, got)
+			}
+		})
+	}
+}
+
+func TestBadLevel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected an out-of-range level to panic")
+		}
+	}()
+	Middleware(Config{Level: 42})
+}
@@ -0,0 +1,221 @@
+package compress
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// encoder is what gzip.Writer and zlib.Writer have in common.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressWriter holds back the first MinSize bytes of a response so it
+// can tell whether compressing is worth it before any headers go out.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *compressor
+	encoding string
+	head     bool
+	status   int
+	wrote    bool
+	started  bool
+	buf      []byte
+	enc      encoder
+	pool     *sync.Pool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wrote {
+		return
+	}
+	if code < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+	cw.wrote = true
+	if code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wrote {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.started {
+		if cw.head {
+			return len(b), nil
+		}
+		return cw.out().Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.c.cfg.MinSize {
+		if err := cw.start(false); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Unwrap lets http.ResponseController reach the original writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) out() io.Writer {
+	if cw.enc != nil {
+		return cw.enc
+	}
+	return cw.ResponseWriter
+}
+
+// start sends the headers, deciding whether to compress, then whatever
+// was held back, unless this is a HEAD response. A flushed response is
+// compressed whatever its size, since more is presumably on the way.
+func (cw *compressWriter) start(flushing bool) error {
+	cw.started = true
+	if cw.shouldCompress(flushing) {
+		h := cw.Header()
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		if !cw.head {
+			cw.pool = &cw.c.gzipPool
+			if cw.encoding == Deflate {
+				cw.pool = &cw.c.zlibPool
+			}
+			cw.enc = cw.pool.Get().(encoder)
+			cw.enc.Reset(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 || cw.head {
+		return nil
+	}
+	_, err := cw.out().Write(buf)
+	return err
+}
+
+func (cw *compressWriter) shouldCompress(flushing bool) bool {
+	switch cw.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" && len(cw.buf) > 0 {
+		ct = http.DetectContentType(cw.buf)
+		h.Set("Content-Type", ct)
+	}
+	if !compressible(ct, cw.c.cfg.ContentTypes) {
+		return false
+	}
+	addVary(h)
+	if cw.encoding == "" {
+		return false
+	}
+	return flushing || cw.size() >= cw.c.cfg.MinSize
+}
+
+// size is how much of the body is known: what was held back or, for a
+// HEAD response that wrote nothing, its Content-Length.
+func (cw *compressWriter) size() int {
+	if cw.head && len(cw.buf) == 0 {
+		if n, err := strconv.Atoi(cw.Header().Get("Content-Length")); err == nil {
+			return n
+		}
+	}
+	return len(cw.buf)
+}
+
+// addVary adds Accept-Encoding to the Vary header, since whether this
+// response is compressed depends on it.
+func addVary(h http.Header) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, "Accept-Encoding") {
+				return
+			}
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+}
+
+// close finishes the response once the handler returns and puts the
+// encoder back in its pool.
+func (cw *compressWriter) close() {
+	if !cw.started && cw.wrote {
+		cw.start(false)
+	}
+	if cw.enc == nil {
+		return
+	}
+	cw.enc.Close()
+	cw.enc.Reset(nil)
+	cw.pool.Put(cw.enc)
+	cw.enc = nil
+}
+
+func (cw *compressWriter) flush() {
+	if !cw.wrote {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.started {
+		cw.start(true)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	cw.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (cw *compressWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return cw.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type flushWriter struct{ *compressWriter }
+
+func (w flushWriter) Flush() { w.flush() }
+
+type hijackWriter struct{ *compressWriter }
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type flushHijackWriter struct{ *compressWriter }
+
+func (w flushHijackWriter) Flush() { w.flush() }
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+// wrap returns cw as a writer that implements http.Flusher and
+// http.Hijacker exactly when the underlying writer does.
+func wrap(cw *compressWriter) http.ResponseWriter {
+	_, canFlush := cw.ResponseWriter.(http.Flusher)
+	_, canHijack := cw.ResponseWriter.(http.Hijacker)
+	switch {
+	case canFlush && canHijack:
+		return flushHijackWriter{cw}
+	case canFlush:
+		return flushWriter{cw}
+	case canHijack:
+		return hijackWriter{cw}
+	}
+	return cw
+}
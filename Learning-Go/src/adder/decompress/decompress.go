@@ -0,0 +1,388 @@
+// Package decompress opens compressed files and archives without being
+// told what they are.
+package decompress
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	Plain Format = iota
+	Gzip
+	Zlib
+	Bzip2
+	Flate
+	Tar
+	Zip
+)
+
+func (f Format) String() string {
+	switch f {
+	case Plain:
+		return "plain"
+	case Gzip:
+		return "gzip"
+	case Zlib:
+		return "zlib"
+	case Bzip2:
+		return "bzip2"
+	case Flate:
+		return "flate"
+	case Tar:
+		return "tar"
+	case Zip:
+		return "zip"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Member describes one file in an archive. Plain and compressed files
+// are treated as an archive with a single member.
+type Member struct {
+	Name    string
+	Size    int64 // -1 if unknown
+	ModTime time.Time
+}
+
+// Reader reads a file opened by OpenAny. Without calls to Next, Read
+// returns the contents of every member back to back. After Next, Read
+// stops at the end of the current member, like tar.Reader.
+type Reader struct {
+	// Formats lists the layers found, outermost first, such as
+	// [gzip tar].
+	Formats []Format
+
+	closers []io.Closer
+	next    func() (*Member, io.Reader, error)
+	cur     io.Reader
+	manual  bool
+	done    bool
+}
+
+// OpenAny opens path and undoes whatever compression and archiving it
+// finds, going by the file's contents rather than its name.
+func OpenAny(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{closers: []io.Closer{f}}
+	if err := r.init(f, path); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *Reader) init(f *os.File, path string) error {
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(4)
+	if bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06")) {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return err
+		}
+		r.Formats = append(r.Formats, Zip)
+		r.next = zipMembers(zr)
+		return nil
+	}
+
+	var stream io.Reader = br
+	format, err := sniff(br)
+	if err != nil {
+		return err
+	}
+	if format == Flate {
+		ok, err := inflatesWhole(f)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			format = Plain
+		}
+	}
+	switch format {
+	case Gzip:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		r.closers = append(r.closers, zr)
+		stream = zr
+	case Zlib:
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return err
+		}
+		r.closers = append(r.closers, zr)
+		stream = zr
+	case Bzip2:
+		stream = bzip2.NewReader(br)
+	case Flate:
+		fr := flate.NewReader(br)
+		r.closers = append(r.closers, fr)
+		stream = fr
+	}
+	if format != Plain {
+		r.Formats = append(r.Formats, format)
+	}
+
+	inner := bufio.NewReader(stream)
+	if isTar(inner) {
+		r.Formats = append(r.Formats, Tar)
+		r.next = tarMembers(tar.NewReader(inner))
+		return nil
+	}
+	if len(r.Formats) == 0 {
+		r.Formats = append(r.Formats, Plain)
+	}
+	r.next = singleMember(inner, trimExt(filepath.Base(path), format))
+	return nil
+}
+
+// sniff identifies the compression applied to br, if any, without
+// consuming anything.
+func sniff(br *bufio.Reader) (Format, error) {
+	head, err := br.Peek(4)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return Plain, err
+	}
+	switch {
+	case len(head) >= 2 && head[0] == 0x1f && head[1] == 0x8b:
+		return Gzip, nil
+	case len(head) >= 4 && string(head[:3]) == "BZh" && head[3] >= '1' && head[3] <= '9':
+		return Bzip2, nil
+	case len(head) >= 2 && head[0]&0x0f == 8 && head[0]>>4 <= 7 && (int(head[0])<<8|int(head[1]))%31 == 0:
+		// The zlib header check passes for about one in 31 text files
+		// starting with "H", "X", "h" or "x", so make sure it decodes.
+		if decodes(br, func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }) {
+			return Zlib, nil
+		}
+	}
+	// Raw flate has no magic number at all, and a fair amount of text
+	// inflates for a while before going wrong, so this is only a first
+	// cut; init then checks the whole file with inflatesWhole.
+	if decodes(br, func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil }) {
+		return Flate, nil
+	}
+	return Plain, nil
+}
+
+// decodes reports whether the start of br decompresses without error.
+func decodes(br *bufio.Reader, open func(io.Reader) (io.Reader, error)) bool {
+	sample, _ := br.Peek(br.Size())
+	if len(sample) == 0 {
+		return false
+	}
+	dr, err := open(bytes.NewReader(sample))
+	if err != nil {
+		return false
+	}
+	n, err := io.Copy(ioutil.Discard, io.LimitReader(dr, 4096))
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		// The sample cut a valid stream short.
+		return n > 0 && len(sample) == br.Size()
+	}
+	return false
+}
+
+// inflatesWhole reports whether all of f is a single raw flate stream:
+// it decompresses without error, ends with a final block, and nothing
+// follows it.
+func inflatesWhole(f *os.File) (bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	cr := &countingReader{r: bufio.NewReader(io.NewSectionReader(f, 0, info.Size()))}
+	if _, err := io.Copy(ioutil.Discard, flate.NewReader(cr)); err != nil {
+		var corrupt flate.CorruptInputError
+		if errors.As(err, &corrupt) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return cr.n == info.Size(), nil
+}
+
+// countingReader counts the bytes flate takes. It is an io.ByteReader so
+// flate reads exactly what it needs rather than buffering ahead.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+func isTar(br *bufio.Reader) bool {
+	block, _ := br.Peek(512)
+	return len(block) == 512 && bytes.HasPrefix(block[257:], []byte("ustar"))
+}
+
+var compressedExt = map[Format][]string{
+	Gzip:  {".gz", ".tgz"},
+	Zlib:  {".zz", ".zlib"},
+	Bzip2: {".bz2", ".tbz2"},
+	Flate: {".deflate"},
+}
+
+func trimExt(name string, f Format) string {
+	for _, ext := range compressedExt[f] {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+func singleMember(r io.Reader, name string) func() (*Member, io.Reader, error) {
+	done := false
+	return func() (*Member, io.Reader, error) {
+		if done {
+			return nil, nil, io.EOF
+		}
+		done = true
+		return &Member{Name: name, Size: -1}, r, nil
+	}
+}
+
+func tarMembers(tr *tar.Reader) func() (*Member, io.Reader, error) {
+	return func() (*Member, io.Reader, error) {
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				return nil, nil, err
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				return &Member{Name: hdr.Name, Size: hdr.Size, ModTime: hdr.ModTime}, tr, nil
+			}
+		}
+	}
+}
+
+func zipMembers(zr *zip.Reader) func() (*Member, io.Reader, error) {
+	files := zr.File
+	return func() (*Member, io.Reader, error) {
+		for len(files) > 0 {
+			zf := files[0]
+			files = files[1:]
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, nil, err
+			}
+			// zip members are independent readers; closing one only
+			// releases its decompressor.
+			return &Member{Name: zf.Name, Size: int64(zf.UncompressedSize64), ModTime: zf.Modified},
+				readAndClose{rc}, nil
+		}
+		return nil, nil, io.EOF
+	}
+}
+
+// readAndClose closes rc as soon as it reports EOF.
+type readAndClose struct {
+	rc io.ReadCloser
+}
+
+func (r readAndClose) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	if err == io.EOF {
+		r.rc.Close()
+	}
+	return n, err
+}
+
+// Next advances to the next member and returns its header. It returns
+// io.EOF after the last one.
+func (r *Reader) Next() (*Member, error) {
+	r.manual = true
+	if r.done {
+		return nil, io.EOF
+	}
+	m, cur, err := r.next()
+	if err != nil {
+		r.done = true
+		r.cur = nil
+		return nil, err
+	}
+	r.cur = cur
+	return m, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if r.manual || r.done {
+				return 0, io.EOF
+			}
+			if _, err := r.Next(); err != nil {
+				return 0, err
+			}
+			r.manual = false
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			if r.manual {
+				return n, io.EOF
+			}
+			r.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close releases every decompressor and the underlying file. It returns
+// the first error encountered.
+func (r *Reader) Close() error {
+	var first error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if err := r.closers[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	r.closers = nil
+	return first
+}
@@ -0,0 +1,201 @@
+package decompress
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const text = "This is a test for go programming."
+
+// bzipped is text compressed with bzip2, which the standard library
+// cannot write.
+const bzipped = "BZh91AY&SY\x8f\x97\x16\x0a\x00\x00\x03\x93\x80@\x01\x04\x00#\xe3\xdc\x00 \x001L\x00\x13B" +
+	"\x8d\x0d\xa9\x93O(Rb$\x07\x0cp \xe7\xcd'\xb2Di=\x92\x7f\x8b\xb9\x22\x9c(HG\xcb\x8b\x05\x00"
+
+func compress(t *testing.T, wrap func(io.Writer) io.WriteCloser, parts ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, p := range parts {
+		w := wrap(&buf)
+		w.Write([]byte(p))
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func tarball(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755})
+	for i, f := range files {
+		tw.WriteHeader(&tar.Header{Name: fmt.Sprintf("dir/%d.txt", i), Mode: 0644, Size: int64(len(f))})
+		tw.Write([]byte(f))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipfile(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("dir/")
+	for i, f := range files {
+		w, _ := zw.Create(fmt.Sprintf("dir/%d.txt", i))
+		w.Write([]byte(f))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipWriter(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+func zlibWriter(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+func flateWriter(w io.Writer) io.WriteCloser {
+	fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+	return fw
+}
+
+func writeFile(t *testing.T, name string, b []byte) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "decompress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const flateLookalike = "[short] skip\n[compiler:gccgo] skip # gccgo has no cover too"
+
+func TestOpenAny(t *testing.T) {
+	data := []struct {
+		name    string
+		file    string
+		content []byte
+		formats string
+		want    string
+	}{
+		{"plain", "a.txt", []byte(text), "[plain]", text},
+		{"gzip", "a.txt.gz", compress(t, gzipWriter, text), "[gzip]", text},
+		{"gzip_multi_member", "a.gz", compress(t, gzipWriter, "first ", "second ", "third"), "[gzip]", "first second third"},
+		{"zlib", "a.zz", compress(t, zlibWriter, text), "[zlib]", text},
+		{"flate", "a.deflate", compress(t, flateWriter, text), "[flate]", text},
+		{"bzip2", "a.bz2", []byte(bzipped), "[bzip2]", text},
+		{"tar", "a.tar", tarball(t, "one ", "two"), "[tar]", "one two"},
+		{"tar_gz", "a.tgz", compress(t, gzipWriter, string(tarball(t, "one ", "two"))), "[gzip tar]", "one two"},
+		{"zip", "a.zip", zipfile(t, "one ", "two"), "[zip]", "one two"},
+		{"zlib_lookalike", "x.txt", []byte("x marks the spot"), "[plain]", "x marks the spot"},
+		// The start of cmd/go/testdata/script/cover_asm.txt, which inflates
+		// to 35 bytes of garbage before the stream runs out.
+		{"flate_lookalike", "y.txt", []byte(flateLookalike), "[plain]", flateLookalike},
+		{"flate_trailing", "a.deflate", append(compress(t, flateWriter, text), "extra"...), "[plain]",
+			string(compress(t, flateWriter, text)) + "extra"},
+		{"sample", "../library/my_data.txt.gz", nil, "[gzip tar]", text},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			path := d.file
+			if d.content != nil {
+				path = writeFile(t, d.file, d.content)
+			}
+			r, err := OpenAny(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != d.want {
+				t.Errorf("expected `%s`, got `%s`", d.want, got)
+			}
+			if formats := fmt.Sprint(r.Formats); formats != d.formats {
+				t.Errorf("expected formats %s, got %s", d.formats, formats)
+			}
+			if err := r.Close(); err != nil {
+				t.Errorf("unexpected close error %v", err)
+			}
+		})
+	}
+}
+
+func TestMembers(t *testing.T) {
+	data := []struct {
+		name    string
+		file    string
+		content []byte
+		want    string
+	}{
+		{"tar", "a.tar", tarball(t, "one", "two"), "dir/0.txt=one dir/1.txt=two"},
+		{"zip", "a.zip", zipfile(t, "one", "two"), "dir/0.txt=one dir/1.txt=two"},
+		{"gzip", "notes.txt.gz", compress(t, gzipWriter, "one"), "notes.txt=one"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			r, err := OpenAny(writeFile(t, d.file, d.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			var got []string
+			for {
+				m, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := ioutil.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, m.Name+"="+string(b))
+			}
+			if strings.Join(got, " ") != d.want {
+				t.Errorf("expected `%s`, got `%s`", d.want, strings.Join(got, " "))
+			}
+		})
+	}
+}
+
+func TestCloseReleasesFile(t *testing.T) {
+	r, err := OpenAny(writeFile(t, "a.gz", compress(t, gzipWriter, text)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := r.closers[0].(*os.File)
+	r.Close()
+	if _, err := f.Stat(); err == nil {
+		t.Error("expected the file to be closed")
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	path := writeFile(t, "bad.gz", []byte("\x1f\x8bnot really gzip"))
+	if _, err := OpenAny(path); err == nil || !strings.Contains(err.Error(), "bad.gz") {
+		t.Errorf("expected an error naming the file, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+// Package jsontime reads JSON timestamps written in any of several
+// layouts and writes them back in one.
+package jsontime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts for Format that aren't time.Parse layouts. UnixSeconds and
+// UnixMillis are written as a JSON number and read from one, or by Parse
+// from a string of digits. A JSON string of digits is never read as Unix
+// time, so a compact date such as "20200502" fails rather than landing
+// in 1970. UnixSeconds rejects values of 1e11 and over,
+// which are far more likely to be milliseconds than dates after the
+// year 5000, so listing it ahead of UnixMillis tells the two apart.
+const (
+	UnixSeconds = "unix"
+	UnixMillis  = "unixms"
+	DateOnly    = "2006-01-02"
+)
+
+// Format says which layouts a timestamp may arrive in and which one it
+// is written in.
+type Format struct {
+	// Accept lists the layouts Parse tries, in order. Empty means just
+	// Canonical.
+	Accept []string
+	// Canonical is the layout Marshal writes. Empty means time.RFC3339.
+	Canonical string
+	// Location is the zone input without an offset is read in, and the
+	// zone every time is converted to. Nil means UTC.
+	Location *time.Location
+}
+
+var (
+	// Default accepts everything Time is documented to and writes
+	// RFC 3339 in UTC, keeping any fraction of a second.
+	Default = Format{
+		Accept:    []string{time.RFC3339Nano, time.RFC822Z, time.RFC1123Z, DateOnly, UnixSeconds, UnixMillis},
+		Canonical: time.RFC3339Nano,
+	}
+	// DateFormat is the Format for Date.
+	DateFormat = Format{
+		Accept:    []string{DateOnly, time.RFC3339Nano},
+		Canonical: DateOnly,
+	}
+	// UnixMillisFormat is the Format for UnixMillisTime.
+	UnixMillisFormat = Format{
+		Accept:    []string{UnixSeconds, UnixMillis, time.RFC3339Nano},
+		Canonical: UnixMillis,
+	}
+)
+
+func (f Format) location() *time.Location {
+	if f.Location == nil {
+		return time.UTC
+	}
+	return f.Location
+}
+
+func (f Format) canonical() string {
+	if f.Canonical == "" {
+		return time.RFC3339
+	}
+	return f.Canonical
+}
+
+// Parse reads s with the first layout in f.Accept that fits and returns
+// it in f's Location.
+func (f Format) Parse(s string) (time.Time, error) {
+	return f.parse(s, true)
+}
+
+// parse is Parse, skipping the Unix layouts unless unix is set.
+func (f Format) parse(s string, unix bool) (time.Time, error) {
+	layouts := f.Accept
+	if len(layouts) == 0 {
+		layouts = []string{f.canonical()}
+	}
+	loc := f.location()
+	for _, layout := range layouts {
+		var t time.Time
+		var err error
+		switch layout {
+		case UnixSeconds, UnixMillis:
+			if !unix {
+				continue
+			}
+			t, err = parseUnix(s, layout)
+		default:
+			t, err = time.ParseInLocation(layout, s, loc)
+		}
+		if err == nil {
+			return t.In(loc), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("jsontime: %q matches none of %s", s, strings.Join(layouts, ", "))
+}
+
+func parseUnix(s, layout string) (time.Time, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if layout == UnixMillis {
+		return time.Unix(n/1000, n%1000*int64(time.Millisecond)), nil
+	}
+	if n >= 1e11 || n <= -1e11 {
+		return time.Time{}, fmt.Errorf("jsontime: %d is out of range for seconds", n)
+	}
+	return time.Unix(n, 0), nil
+}
+
+// Format writes t in f's Location and Canonical layout.
+func (f Format) Format(t time.Time) string {
+	t = t.In(f.location())
+	switch layout := f.canonical(); layout {
+	case UnixSeconds:
+		return strconv.FormatInt(t.Unix(), 10)
+	case UnixMillis:
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	default:
+		return t.Format(layout)
+	}
+}
+
+// Marshal returns t as JSON: null for the zero time, a number for the
+// Unix layouts and a string otherwise.
+func (f Format) Marshal(t time.Time) ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	s := f.Format(t)
+	switch f.canonical() {
+	case UnixSeconds, UnixMillis:
+		return []byte(s), nil
+	}
+	return json.Marshal(s)
+}
+
+// Unmarshal parses a JSON string or number into t. Only a number is
+// read as Unix time. null leaves t alone, as encoding/json does for
+// other types, and "" sets it to the zero time.
+func (f Format) Unmarshal(b []byte, t *time.Time) error {
+	b = bytes.TrimSpace(b)
+	if string(b) == "null" {
+		return nil
+	}
+	s := string(b)
+	quoted := len(b) > 0 && b[0] == '"'
+	if quoted {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if strings.TrimSpace(s) == "" {
+			*t = time.Time{}
+			return nil
+		}
+	}
+	parsed, err := f.parse(s, !quoted)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// Time is a time.Time that uses Default for JSON. To use another Format,
+// wrap time.Time the same way:
+//
+//	type RFC822ZTime struct{ time.Time }
+//
+//	func (t RFC822ZTime) MarshalJSON() ([]byte, error) { return rfc822z.Marshal(t.Time) }
+//	func (t *RFC822ZTime) UnmarshalJSON(b []byte) error { return rfc822z.Unmarshal(b, &t.Time) }
+type Time struct {
+	time.Time
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return Default.Marshal(t.Time)
+}
+
+func (t *Time) UnmarshalJSON(b []byte) error {
+	return Default.Unmarshal(b, &t.Time)
+}
+
+// Date is a time.Time that uses DateFormat for JSON.
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return DateFormat.Marshal(d.Time)
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	return DateFormat.Unmarshal(b, &d.Time)
+}
+
+// UnixMillisTime is a time.Time that uses UnixMillisFormat for JSON.
+type UnixMillisTime struct {
+	time.Time
+}
+
+func (t UnixMillisTime) MarshalJSON() ([]byte, error) {
+	return UnixMillisFormat.Marshal(t.Time)
+}
+
+func (t *UnixMillisTime) UnmarshalJSON(b []byte) error {
+	return UnixMillisFormat.Unmarshal(b, &t.Time)
+}
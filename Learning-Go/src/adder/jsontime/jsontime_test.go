@@ -0,0 +1,141 @@
+package jsontime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUnmarshal(t *testing.T) {
+	want := time.Date(2020, 5, 1, 14, 30, 0, 0, time.UTC)
+	data := []struct {
+		name  string
+		input string
+		want  time.Time
+		err   string
+	}{
+		{"rfc3339", `"2020-05-01T14:30:00Z"`, want, ""},
+		{"rfc3339_offset", `"2020-05-01T16:30:00+02:00"`, want, ""},
+		{"rfc3339_nano", `"2020-05-01T14:30:00.250Z"`, want.Add(250 * time.Millisecond), ""},
+		{"rfc822z", `"01 May 20 10:30 -0400"`, want, ""},
+		{"rfc1123z", `"Fri, 01 May 2020 14:30:00 +0000"`, want, ""},
+		{"date_only", `"2020-05-01"`, time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), ""},
+		{"unix_seconds", `1588343400`, want, ""},
+		{"unix_seconds_string", `"1588343400"`, time.Time{}, `jsontime: "1588343400" matches none of ` +
+			time.RFC3339Nano + ", " + time.RFC822Z + ", " + time.RFC1123Z + ", 2006-01-02, unix, unixms"},
+		{"compact_date", `"20200502"`, time.Time{}, `jsontime: "20200502" matches none of ` +
+			time.RFC3339Nano + ", " + time.RFC822Z + ", " + time.RFC1123Z + ", 2006-01-02, unix, unixms"},
+		{"unix_millis", `1588343400250`, want.Add(250 * time.Millisecond), ""},
+		{"empty", `""`, time.Time{}, ""},
+		{"blank", `"  "`, time.Time{}, ""},
+		{"bad", `"May 1st"`, time.Time{}, `jsontime: "May 1st" matches none of ` +
+			time.RFC3339Nano + ", " + time.RFC822Z + ", " + time.RFC1123Z + ", 2006-01-02, unix, unixms"},
+		{"bool", `true`, time.Time{}, `jsontime: "true" matches none of ` +
+			time.RFC3339Nano + ", " + time.RFC822Z + ", " + time.RFC1123Z + ", 2006-01-02, unix, unixms"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			got := Time{time.Now()}
+			err := json.Unmarshal([]byte(d.input), &got)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != d.err {
+				t.Errorf("expected error `%s`, got `%s`", d.err, errMsg)
+			}
+			if err == nil && !got.Equal(d.want) {
+				t.Errorf("expected %v, got %v", d.want, got.Time)
+			}
+			if err == nil && !got.IsZero() && got.Location() != time.UTC {
+				t.Errorf("expected UTC, got %v", got.Location())
+			}
+		})
+	}
+}
+
+func TestNullLeavesValue(t *testing.T) {
+	var v struct {
+		When Time `json:"when"`
+	}
+	v.When = Time{time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)}
+	if err := json.Unmarshal([]byte(`{"when": null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.When.Year() != 2020 {
+		t.Errorf("expected null to leave the time alone, got %v", v.When.Time)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	when := time.Date(2020, 5, 1, 23, 30, 0, 250e6, ny)
+	data := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"time", Time{when}, `"2020-05-02T03:30:00.25Z"`},
+		{"zero_time", Time{}, `null`},
+		{"date", Date{when}, `"2020-05-02"`},
+		{"unix_millis", UnixMillisTime{when}, `1588390200250`},
+		{"zero_unix_millis", UnixMillisTime{}, `null`},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			b, err := json.Marshal(d.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != d.want {
+				t.Errorf("expected `%s`, got `%s`", d.want, string(b))
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	f := Format{
+		Accept:    []string{"2006-01-02 15:04", time.RFC822Z},
+		Canonical: time.RFC822Z,
+		Location:  ny,
+	}
+	data := []struct {
+		input string
+		want  string
+	}{
+		// No offset in the input, so it is read in New York time.
+		{`"2020-05-01 09:00"`, `"01 May 20 09:00 -0400"`},
+		{`"01 May 20 14:30 +0100"`, `"01 May 20 09:30 -0400"`},
+		{`"01 Jan 20 14:30 +0000"`, `"01 Jan 20 09:30 -0500"`},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			var tm time.Time
+			if err := f.Unmarshal([]byte(d.input), &tm); err != nil {
+				t.Fatal(err)
+			}
+			b, err := f.Marshal(tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != d.want {
+				t.Errorf("expected `%s`, got `%s`", d.want, string(b))
+			}
+		})
+	}
+}
+
+func TestUnixSecondsRange(t *testing.T) {
+	f := Format{Accept: []string{UnixSeconds}}
+	if _, err := f.Parse("1588343400250"); err == nil {
+		t.Error("expected a millisecond value to be rejected as seconds")
+	}
+}
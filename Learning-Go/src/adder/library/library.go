@@ -1,16 +1,16 @@
 package main
 
 import (
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/adder/jsontime"
 )
 
 type Reader interface {
@@ -44,21 +44,6 @@ func countLetters(r io.Reader) (map[string]int, error) {
 	}
 }
 
-func buildGZipReader(fileName string) (*gzip.Reader, func(), error) {
-	r, err := os.Open(fileName)
-	if err != nil {
-		return nil, nil, err
-	}
-	gr, err := gzip.NewReader(r)
-	if err != nil {
-		return nil, nil, err
-	}
-	return gr, func() {
-		gr.Close()
-		r.Close()
-	}, nil
-}
-
 type Closer interface {
 	Close() error
 }
@@ -83,28 +68,6 @@ func (hh HelloHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Hello!\n"))
 }
 
-var securityMsg = []byte("You didn't give the secret password\n")
-func RequestTimer(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		h.ServeHTTP(w, r)
-		end := time.Now()
-		log.Printf("request time for %s: %v", r.URL.Path, end.Sub(start))
-	})
-}
-func TerribleSecurityProvider(password string) func(http.Handler) http.Handler {
-	return func(h http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Header.Get("X-Secret-Password") != password {
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write(securityMsg)
-				return
-			}
-			h.ServeHTTP(w, r)
-		})
-	}
-}
-
 func main() {
 	//s := "The quick brown fox jumped over the lazy dog"
 	//sr := strings.NewReader(s)
@@ -114,11 +77,11 @@ func main() {
 	//}
 	//fmt.Println(counts)
 	//
-	//r, closer, err := buildGZipReader("my_data.txt.gz")
+	//r, err := decompress.OpenAny("my_data.txt.gz")
 	//if err != nil {
 	//	return
 	//}
-	//defer closer()
+	//defer r.Close()
 	//counts2, err := countLetters(r)
 	//if err != nil {
 	//	return
@@ -148,7 +111,7 @@ func main() {
 
 	type Order struct {
 		ID            string        `json:"id"`
-		DateOrdered time.Time `json:"date_ordered"`
+		DateOrdered jsontime.Time `json:"date_ordered"`
 		CustomerID    string        `json:"customer_id"`
 		Items         []Item        `json:"items"`
 	}
@@ -185,6 +148,15 @@ func main() {
 	//	// process t
 	//}
 
+	//dec := records.NewDecoder(os.Stdin, records.Config{DeadLetter: os.Stderr})
+	//out := records.NewWriter(os.Stdout, records.WriterConfig{Format: records.CSV, FlushEvery: 100})
+	//if _, err := records.Copy(out, dec); err != nil {
+	//	panic(err)
+	//}
+	//if err := out.Close(); err != nil {
+	//	panic(err)
+	//}
+
 	//var b bytes.Buffer
 	//enc := json.NewEncoder(&b)
 	//for _, input := range allInputs {
@@ -200,21 +172,17 @@ func main() {
 		time.Time
 	}
 
+	//var rfc822z = jsontime.Format{
+	//	Accept:    []string{time.RFC822Z, time.RFC3339},
+	//	Canonical: time.RFC822Z,
+	//}
+	//
 	//func (rt RFC822ZTime) MarshalJSON() ([]byte, error) {
-	//	out := rt.Time.Format(time.RFC822Z)
-	//	return []byte(`"` + out + `"`), nil
+	//	return rfc822z.Marshal(rt.Time)
 	//}
 	//
 	//func (rt *RFC822ZTime) UnmarshalJSON(b []byte) error {
-	//	if string(b) == "null" {
-	//	return nil
-	//}
-	//	t, err := time.Parse(`"`+time.RFC822Z+`"`, string(b))
-	//	if err != nil {
-	//	return err
-	//}
-	//	*rt = RFC822ZTime{t}
-	//	return nil
+	//	return rfc822z.Unmarshal(b, &rt.Time)
 	//}
 
 	//type Order struct {
@@ -305,9 +273,22 @@ func main() {
 	//mux.Handle("/person/", http.StripPrefix("/person", person))
 	//mux.Handle("/dog/", http.StripPrefix("/dog", dog))
 
-	//terribleSecurity := TerribleSecurityProvider("GOPHER")
+	//people := router.New()
+	//people.HandleFunc(http.MethodGet, "/{name}/greet", func(w http.ResponseWriter, r *http.Request) {
+	//	w.Write([]byte("greetings, " + router.URLParam(r.Context(), "name") + "!\n"))
+	//})
+	//routes := router.New()
+	//routes.Mount("/person", people)
+
+	//authn := &auth.Authenticator{
+	//	Keys:           keys,  // from auth.LoadAPIKeys("keys.json")
+	//	Users:          users, // from auth.LoadCredentials("htpasswd")
+	//	LegacyPassword: "GOPHER",
+	//}
+	//m := metrics.New(metrics.Config{})
+	//mux.Handle("/metrics", m.Handler())
 	//
-	//mux.Handle("/hello", terribleSecurity(RequestTimer(
+	//mux.Handle("/hello", authn.Middleware(m.Middleware(
 	//	http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	//		w.Write([]byte("Hello!\n"))
 	//	}))))
@@ -315,6 +296,16 @@ func main() {
 	//helloHandler := func(w http.ResponseWriter, r *http.Request) {
 	//	w.Write([]byte("Hello!\n"))
 	//}
-	//chain := alice.New(terribleSecurity, RequestTimer).ThenFunc(helloHandler)
-	//mux.Handle("/hello", chain)
+	//chain := middleware.New(m.Middleware, compress.Middleware(compress.Config{}), authn.Middleware).
+	//	When(middleware.Condition{Methods: []string{http.MethodPost}}, auth.RequireScope("hello:write"))
+	//mux.Handle("/hello", chain.ThenFunc(helloHandler))
+	//chain.Dump(os.Stdout, routes.Routes())
+
+	//routes.HandleFunc(http.MethodPost, "/orders", func(w http.ResponseWriter, r *http.Request) {
+	//	var o Order
+	//	if !bind.Bind(w, r, &o) {
+	//		return
+	//	}
+	//	w.WriteHeader(http.StatusCreated)
+	//})
 }
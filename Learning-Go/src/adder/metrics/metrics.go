@@ -0,0 +1,234 @@
+// Package metrics records HTTP server metrics and serves them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/statuswriter"
+)
+
+// DefaultBuckets are the latency histogram bounds in seconds, the same
+// as the Prometheus client libraries use.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Config struct {
+	// Namespace prefixes every metric name, as in orders_http_requests_total.
+	Namespace string
+	// Buckets are the upper bounds of the latency histogram in seconds.
+	// Nil means DefaultBuckets.
+	Buckets []float64
+}
+
+// Metrics counts requests, their latency and how many are in flight.
+// Requests are labeled by route pattern, method and status class, never
+// by raw path, so the number of series stays bounded.
+type Metrics struct {
+	namespace string
+	buckets   []float64
+	now       func() time.Time
+
+	mu       sync.Mutex
+	series   map[seriesKey]*series
+	inFlight map[string]int64
+}
+
+type seriesKey struct {
+	route, method, class string
+}
+
+type series struct {
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+func New(cfg Config) *Metrics {
+	buckets := cfg.Buckets
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		namespace: cfg.Namespace,
+		buckets:   buckets,
+		now:       time.Now,
+		series:    map[seriesKey]*series{},
+		inFlight:  map[string]int64{},
+	}
+}
+
+type routeKey struct{}
+
+// SetRoute records the pattern of the route that matched the request in
+// ctx, such as "/users/{id}". Set it as a router's match hook, such as
+// router.Router.OnMatch; the Middleware reads it once the handler
+// returns.
+func SetRoute(ctx context.Context, pattern string) {
+	if p, ok := ctx.Value(routeKey{}).(*string); ok {
+		*p = pattern
+	}
+}
+
+// Unmatched labels requests no router claimed.
+const Unmatched = "unmatched"
+
+func (m *Metrics) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		method := normalizeMethod(req.Method)
+		m.addInFlight(method, 1)
+		defer m.addInFlight(method, -1)
+
+		start := m.now()
+		var route string
+		req = req.WithContext(context.WithValue(req.Context(), routeKey{}, &route))
+		sw, wrapped := statuswriter.Wrap(rw)
+		defer func() {
+			// A handler that panics before writing anything ends in a 500
+			// from whatever recovers it, so count it as one.
+			p := recover()
+			status := sw.Status()
+			if p != nil && !sw.Wrote() {
+				status = http.StatusInternalServerError
+			}
+			if route == "" {
+				route = Unmatched
+			}
+			m.observe(seriesKey{route, method, statusClass(status)}, m.now().Sub(start))
+			if p != nil {
+				panic(p)
+			}
+		}()
+		h.ServeHTTP(wrapped, req)
+	})
+}
+
+func normalizeMethod(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	}
+	return "OTHER"
+}
+
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "other"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+func (m *Metrics) addInFlight(method string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[method] += n
+}
+
+func (m *Metrics) observe(k seriesKey, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[k]
+	if !ok {
+		s = &series{buckets: make([]uint64, len(m.buckets))}
+		m.series[k] = s
+	}
+	secs := d.Seconds()
+	s.count++
+	s.sum += secs
+	for i, le := range m.buckets {
+		if secs <= le {
+			s.buckets[i]++
+		}
+	}
+}
+
+// Handler serves the metrics, conventionally on /metrics.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WriteTo(rw)
+	})
+}
+
+// WriteTo writes every metric in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	keys := make([]seriesKey, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.class < b.class
+	})
+	var b strings.Builder
+	requests := m.name("http_requests_total")
+	fmt.Fprintf(&b, "# HELP %s Requests handled, by route, method and status class.\n", requests)
+	fmt.Fprintf(&b, "# TYPE %s counter\n", requests)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s{%s} %d\n", requests, k.labels(), m.series[k].count)
+	}
+	duration := m.name("http_request_duration_seconds")
+	fmt.Fprintf(&b, "# HELP %s Request latency, by route, method and status class.\n", duration)
+	fmt.Fprintf(&b, "# TYPE %s histogram\n", duration)
+	for _, k := range keys {
+		s, labels := m.series[k], k.labels()
+		for i, le := range m.buckets {
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", duration, labels, formatFloat(le), s.buckets[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", duration, labels, s.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", duration, labels, formatFloat(s.sum))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", duration, labels, s.count)
+	}
+	inFlight := m.name("http_requests_in_flight")
+	methods := make([]string, 0, len(m.inFlight))
+	for method := range m.inFlight {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	fmt.Fprintf(&b, "# HELP %s Requests being handled, by method.\n", inFlight)
+	fmt.Fprintf(&b, "# TYPE %s gauge\n", inFlight)
+	for _, method := range methods {
+		fmt.Fprintf(&b, "%s{method=\"%s\"} %d\n", inFlight, method, m.inFlight[method])
+	}
+	m.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (m *Metrics) name(s string) string {
+	if m.namespace == "" {
+		return s
+	}
+	return m.namespace + "_" + s
+}
+
+func (k seriesKey) labels() string {
+	return fmt.Sprintf(`route="%s",method="%s",code="%s"`, escape(k.route), k.method, k.class)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(m *Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New(Config{Namespace: "orders", Buckets: []float64{0.1, 0.01}})
+	clock := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	m.now = func() time.Time {
+		clock = clock.Add(50 * time.Millisecond)
+		return clock
+	}
+	var inFlight string
+	h := m.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch {
+		case strings.HasPrefix(req.URL.Path, "/users/"):
+			SetRoute(req.Context(), "/users/{id}")
+			inFlight = scrape(m)
+			if req.Method == http.MethodPost {
+				http.Error(rw, "nope", http.StatusBadRequest)
+			}
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/users/1"},
+		{http.MethodGet, "/users/2"},
+		{http.MethodPost, "/users/3"},
+		{"BREW", "/users/4"},
+		{http.MethodGet, "/favicon.ico"},
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(r.method, r.path, nil))
+	}
+
+	got := scrape(m)
+	for _, want := range []string{
+		"# TYPE orders_http_requests_total counter\n",
+		`orders_http_requests_total{route="/users/{id}",method="GET",code="2xx"} 2` + "\n",
+		`orders_http_requests_total{route="/users/{id}",method="POST",code="4xx"} 1` + "\n",
+		`orders_http_requests_total{route="/users/{id}",method="OTHER",code="2xx"} 1` + "\n",
+		`orders_http_requests_total{route="unmatched",method="GET",code="4xx"} 1` + "\n",
+		`orders_http_request_duration_seconds_bucket{route="/users/{id}",method="GET",code="2xx",le="0.01"} 0` + "\n",
+		`orders_http_request_duration_seconds_bucket{route="/users/{id}",method="GET",code="2xx",le="0.1"} 2` + "\n",
+		`orders_http_request_duration_seconds_bucket{route="/users/{id}",method="GET",code="2xx",le="+Inf"} 2` + "\n",
+		`orders_http_request_duration_seconds_count{route="/users/{id}",method="GET",code="2xx"} 2` + "\n",
+		`orders_http_requests_in_flight{method="GET"} 0` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain `%s`, got:\n%s", strings.TrimSpace(want), got)
+		}
+	}
+	if strings.Contains(got, "/users/1") || strings.Contains(got, "favicon") {
+		t.Errorf("raw paths leaked into labels:\n%s", got)
+	}
+	if !strings.Contains(inFlight, `orders_http_requests_in_flight{method="OTHER"} 1`) {
+		t.Errorf("expected one in-flight request while handling, got:\n%s", inFlight)
+	}
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	data := []struct {
+		name      string
+		rw        http.ResponseWriter
+		canFlush  bool
+		canHijack bool
+		status    string
+	}{
+		{"flusher", httptest.NewRecorder(), true, false, "2xx"},
+		{"both", hijackRecorder{httptest.NewRecorder()}, true, true, "1xx"},
+		{"neither", struct{ http.ResponseWriter }{httptest.NewRecorder()}, false, false, "2xx"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			m := New(Config{})
+			h := m.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				f, canFlush := rw.(http.Flusher)
+				hj, canHijack := rw.(http.Hijacker)
+				if canFlush != d.canFlush || canHijack != d.canHijack {
+					t.Errorf("expected flush %v hijack %v, got %v %v", d.canFlush, d.canHijack, canFlush, canHijack)
+				}
+				if canHijack {
+					hj.Hijack()
+				} else if canFlush {
+					f.Flush()
+				}
+			}))
+			h.ServeHTTP(d.rw, httptest.NewRequest(http.MethodGet, "/", nil))
+			if want := `code="` + d.status + `"`; !strings.Contains(scrape(m), want) {
+				t.Errorf("expected %s, got:\n%s", want, scrape(m))
+			}
+		})
+	}
+}
+
+func TestPanic(t *testing.T) {
+	data := []struct {
+		name   string
+		status int
+		class  string
+	}{
+		{"before_writing", 0, "5xx"},
+		{"after_writing", http.StatusAccepted, "2xx"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			m := New(Config{})
+			h := m.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if d.status != 0 {
+					rw.WriteHeader(d.status)
+				}
+				panic("boom")
+			}))
+			func() {
+				defer func() {
+					if recover() != "boom" {
+						t.Error("expected the panic to carry on up")
+					}
+				}()
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			}()
+			if want := `code="` + d.class + `"} 1`; !strings.Contains(scrape(m), want) {
+				t.Errorf("expected %s, got:\n%s", want, scrape(m))
+			}
+			if want := `http_requests_in_flight{method="GET"} 0`; !strings.Contains(scrape(m), want) {
+				t.Errorf("expected %s, got:\n%s", want, scrape(m))
+			}
+		})
+	}
+}
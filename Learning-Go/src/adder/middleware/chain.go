@@ -0,0 +1,184 @@
+// Package middleware composes HTTP server middleware.
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/adder/router"
+)
+
+type Middleware func(http.Handler) http.Handler
+
+// Chain is an immutable, ordered list of middleware. The first one added
+// is the outermost: it sees the request first and can stop it before the
+// rest of the chain runs.
+type Chain struct {
+	links []link
+}
+
+type link struct {
+	name string
+	mw   Middleware
+	when *Condition
+}
+
+func New(mws ...Middleware) Chain {
+	return Chain{}.Append(mws...)
+}
+
+// Append returns a new chain with mws added inside the existing ones.
+// c itself is unchanged, so one base chain can be extended in several
+// directions.
+func (c Chain) Append(mws ...Middleware) Chain {
+	out := c.clone(len(mws))
+	for _, mw := range mws {
+		out.links = append(out.links, link{name: funcName(mw), mw: mw})
+	}
+	return out
+}
+
+// AppendNamed is Append for a single middleware, with the name Dump
+// shows for it.
+func (c Chain) AppendNamed(name string, mw Middleware) Chain {
+	out := c.clone(1)
+	out.links = append(out.links, link{name: name, mw: mw})
+	return out
+}
+
+// Extend returns a new chain with other's middleware added inside c's.
+func (c Chain) Extend(other Chain) Chain {
+	out := c.clone(len(other.links))
+	out.links = append(out.links, other.links...)
+	return out
+}
+
+// When returns a new chain with mws added, applied only to requests that
+// match cond. Others skip straight to the next link.
+func (c Chain) When(cond Condition, mws ...Middleware) Chain {
+	out := c.clone(len(mws))
+	for _, mw := range mws {
+		out.links = append(out.links, link{name: funcName(mw), mw: mw, when: &cond})
+	}
+	return out
+}
+
+func (c Chain) clone(extra int) Chain {
+	links := make([]link, len(c.links), len(c.links)+extra)
+	copy(links, c.links)
+	return Chain{links: links}
+}
+
+// Then wraps h, or http.DefaultServeMux if h is nil.
+func (c Chain) Then(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	for i := len(c.links) - 1; i >= 0; i-- {
+		l := c.links[i]
+		if l.when == nil {
+			h = l.mw(h)
+			continue
+		}
+		h = conditional(*l.when, l.mw, h)
+	}
+	return h
+}
+
+func (c Chain) ThenFunc(f http.HandlerFunc) http.Handler {
+	if f == nil {
+		return c.Then(nil)
+	}
+	return c.Then(f)
+}
+
+func conditional(cond Condition, mw Middleware, next http.Handler) http.Handler {
+	wrapped := mw(next)
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if cond.Match(req.Method, req.URL.Path) {
+			wrapped.ServeHTTP(rw, req)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// Condition selects requests by method and path prefix. An empty field
+// matches everything.
+type Condition struct {
+	Methods      []string
+	PathPrefixes []string
+}
+
+func (c Condition) Match(method, path string) bool {
+	return matchAny(c.Methods, func(m string) bool { return m == method }) &&
+		matchAny(c.PathPrefixes, func(p string) bool { return strings.HasPrefix(path, p) })
+}
+
+func matchAny(list []string, f func(string) bool) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		if f(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Condition) String() string {
+	var parts []string
+	if len(c.Methods) > 0 {
+		parts = append(parts, "method "+strings.Join(c.Methods, "|"))
+	}
+	if len(c.PathPrefixes) > 0 {
+		parts = append(parts, "path "+strings.Join(c.PathPrefixes, "|")+"*")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Names lists the middleware that would run for a request with method
+// and path, outermost first.
+func (c Chain) Names(method, path string) []string {
+	var out []string
+	for _, l := range c.links {
+		if l.when == nil || l.when.Match(method, path) {
+			out = append(out, l.name)
+		}
+	}
+	return out
+}
+
+// Dump writes, for each route, the middleware the chain runs in front of
+// it, in order. Path prefixes are compared with the route's pattern.
+// Middleware registered on the router itself is not shown.
+func (c Chain) Dump(w io.Writer, routes []router.Route) {
+	for _, r := range routes {
+		fmt.Fprintf(w, "%-7s %s\n", r.Method, r.Pattern)
+		for i, name := range c.Names(r.Method, r.Pattern) {
+			fmt.Fprintf(w, "        %d. %s\n", i+1, name)
+		}
+	}
+}
+
+var closureSuffix = regexp.MustCompile(`\.func\d+(\.\d+)*$|-fm$`)
+
+// funcName turns a function's symbol into something readable, such as
+// auth.RequireScope for a closure it returned.
+func funcName(f interface{}) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "?"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return closureSuffix.ReplaceAllString(name, "")
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/adder/auth"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/adder/metrics"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/adder/router"
+)
+
+// trace records entry and exit of each middleware in order.
+type trace struct {
+	steps []string
+}
+
+func (tr *trace) mw(name string) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			tr.steps = append(tr.steps, name+">")
+			h.ServeHTTP(rw, req)
+			tr.steps = append(tr.steps, "<"+name)
+		})
+	}
+}
+
+func (tr *trace) handler(rw http.ResponseWriter, req *http.Request) {
+	tr.steps = append(tr.steps, "handler")
+}
+
+func (tr *trace) stop(rw http.ResponseWriter, req *http.Request) {
+	tr.steps = append(tr.steps, "stop")
+	rw.WriteHeader(http.StatusForbidden)
+}
+
+func TestOrder(t *testing.T) {
+	tr := &trace{}
+	stopper := func(h http.Handler) http.Handler { return http.HandlerFunc(tr.stop) }
+	base := New(tr.mw("a"), tr.mw("b"))
+	data := []struct {
+		name   string
+		chain  Chain
+		method string
+		path   string
+		want   string
+		code   int
+	}{
+		{"new", base, "GET", "/", "a> b> handler <b <a", 200},
+		{"append", base.Append(tr.mw("c")), "GET", "/", "a> b> c> handler <c <b <a", 200},
+		{"extend", base.Extend(New(tr.mw("c"), tr.mw("d"))), "GET", "/", "a> b> c> d> handler <d <c <b <a", 200},
+		{"short_circuit", base.Append(stopper, tr.mw("c")), "GET", "/", "a> b> stop <b <a", 403},
+		{"when_path_matches", base.When(Condition{PathPrefixes: []string{"/admin/"}}, tr.mw("admin")), "GET", "/admin/users",
+			"a> b> admin> handler <admin <b <a", 200},
+		{"when_path_skips", base.When(Condition{PathPrefixes: []string{"/admin/"}}, tr.mw("admin")), "GET", "/users",
+			"a> b> handler <b <a", 200},
+		{"when_method", base.When(Condition{Methods: []string{"POST"}}, stopper), "POST", "/", "a> b> stop <b <a", 403},
+		{"when_method_skips", base.When(Condition{Methods: []string{"POST"}}, stopper), "GET", "/", "a> b> handler <b <a", 200},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			tr.steps = nil
+			rec := httptest.NewRecorder()
+			d.chain.ThenFunc(tr.handler).ServeHTTP(rec, httptest.NewRequest(d.method, d.path, nil))
+			if got := strings.Join(tr.steps, " "); got != d.want {
+				t.Errorf("expected `%s`, got `%s`", d.want, got)
+			}
+			if rec.Code != d.code {
+				t.Errorf("expected %d, got %d", d.code, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAppendDoesNotAlias(t *testing.T) {
+	tr := &trace{}
+	base := New(tr.mw("a"))
+	// Give base spare capacity, the usual way two appends end up
+	// sharing and overwriting one backing array.
+	base.links = append(make([]link, 0, 8), base.links...)
+	left := base.Append(tr.mw("left"))
+	base.Append(tr.mw("right"))
+	tr.steps = nil
+	left.ThenFunc(tr.handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got := strings.Join(tr.steps, " "); got != "a> left> handler <left <a" {
+		t.Errorf("expected the left branch untouched, got `%s`", got)
+	}
+}
+
+func TestDump(t *testing.T) {
+	m := metrics.New(metrics.Config{})
+	a := &auth.Authenticator{}
+	c := New(m.Middleware).
+		AppendNamed("authenticate", a.Middleware).
+		When(Condition{Methods: []string{"DELETE"}, PathPrefixes: []string{"/users/"}}, auth.RequireScope("users:delete"))
+
+	r := router.New()
+	r.HandleFunc(http.MethodGet, "/users/{id}", func(rw http.ResponseWriter, req *http.Request) {})
+	r.HandleFunc(http.MethodDelete, "/users/{id}", func(rw http.ResponseWriter, req *http.Request) {})
+
+	var b strings.Builder
+	c.Dump(&b, r.Routes())
+	want := `DELETE  /users/{id}
+        1. metrics.(*Metrics).Middleware
+        2. authenticate
+        3. auth.RequireScope
+GET     /users/{id}
+        1. metrics.(*Metrics).Middleware
+        2. authenticate
+`
+	if b.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, b.String())
+	}
+}
@@ -0,0 +1,213 @@
+package records
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Config controls how a Decoder reads and what it does with bad records.
+type Config struct {
+	// MaxRecordBytes caps the size of a single record, which is all the
+	// Decoder ever holds in memory. Zero means 1 MB.
+	MaxRecordBytes int
+	// MaxItems caps the items in one order. Zero means 1000.
+	MaxItems int
+	// Skip drops bad records instead of returning them from Next.
+	Skip bool
+	// DeadLetter, if set, receives each bad record as a JSON line with
+	// its index, offset, error and raw text. The record is then skipped
+	// as if Skip were set.
+	DeadLetter io.Writer
+}
+
+var (
+	ErrTooLarge  = errors.New("record exceeds the size limit")
+	ErrNotObject = errors.New("record is not a JSON object")
+)
+
+// RecordError is a record that could not be read or failed validation.
+// Index counts records from zero, bad ones included; Offset is the
+// position of the record's first byte in the input.
+type RecordError struct {
+	Index  int
+	Offset int64
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d at byte %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+type deadLetter struct {
+	Index  int    `json:"index"`
+	Offset int64  `json:"offset"`
+	Error  string `json:"error"`
+	Record string `json:"record"`
+}
+
+// Decoder reads Orders one at a time from newline-delimited or simply
+// concatenated JSON objects.
+type Decoder struct {
+	cfg     Config
+	r       *bufio.Reader
+	dead    *json.Encoder
+	buf     []byte
+	offset  int64
+	index   int
+	skipped int
+	err     error
+}
+
+func NewDecoder(r io.Reader, cfg Config) *Decoder {
+	if cfg.MaxRecordBytes <= 0 {
+		cfg.MaxRecordBytes = 1 << 20
+	}
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = 1000
+	}
+	d := &Decoder{cfg: cfg, r: bufio.NewReader(r)}
+	if cfg.DeadLetter != nil {
+		d.dead = json.NewEncoder(cfg.DeadLetter)
+	}
+	return d
+}
+
+// Next returns the next valid Order, or io.EOF at the end of the input.
+// A bad record comes back as a *RecordError unless Config says to skip
+// it; either way, calling Next again carries on with the record after
+// it. Errors from the underlying reader are final.
+func (d *Decoder) Next() (Order, error) {
+	for d.err == nil {
+		raw, start, err := d.frame()
+		if err == io.EOF {
+			d.err = io.EOF
+			break
+		}
+		if d.err != nil {
+			break
+		}
+		var o Order
+		if err == nil {
+			err = json.Unmarshal(raw, &o)
+		}
+		if err == nil {
+			err = validate(o, d.cfg.MaxItems)
+		}
+		index := d.index
+		d.index++
+		if err == nil {
+			return o, nil
+		}
+		if d.dead != nil {
+			if werr := d.dead.Encode(deadLetter{index, start, err.Error(), string(raw)}); werr != nil {
+				d.err = fmt.Errorf("records: writing dead letter: %w", werr)
+				break
+			}
+			d.skipped++
+			continue
+		}
+		if d.cfg.Skip {
+			d.skipped++
+			continue
+		}
+		return Order{}, &RecordError{Index: index, Offset: start, Err: err}
+	}
+	return Order{}, d.err
+}
+
+// Skipped returns how many bad records were dropped or dead-lettered.
+func (d *Decoder) Skipped() int {
+	return d.skipped
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// frame reads the next top-level value, keeping at most MaxRecordBytes
+// of it. A value that doesn't start with { is read to the end of its
+// line so the next one can be found. It returns io.EOF when only
+// whitespace is left, a record-level error for a bad record, and sets
+// d.err when reading fails.
+func (d *Decoder) frame() ([]byte, int64, error) {
+	d.buf = d.buf[:0]
+	b, err := d.readByte()
+	for err == nil && isSpace(b) {
+		b, err = d.readByte()
+	}
+	if err != nil {
+		return nil, d.offset, err
+	}
+	start := d.offset - 1
+	tooLarge := false
+	keep := func(b byte) {
+		if len(d.buf) < d.cfg.MaxRecordBytes {
+			d.buf = append(d.buf, b)
+		} else {
+			tooLarge = true
+		}
+	}
+	keep(b)
+
+	if b != '{' {
+		for {
+			b, err = d.readByte()
+			if err != nil || b == '\n' {
+				break
+			}
+			keep(b)
+		}
+		if err != nil && err != io.EOF {
+			return nil, start, err
+		}
+		return d.buf, start, ErrNotObject
+	}
+
+	depth, inString, escaped := 1, false, false
+	for depth > 0 {
+		b, err = d.readByte()
+		if err == io.EOF {
+			return d.buf, start, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, start, err
+		}
+		keep(b)
+		switch {
+		case escaped:
+			escaped = false
+		case inString && b == '\\':
+			escaped = true
+		case b == '"':
+			inString = !inString
+		case inString:
+		case b == '{' || b == '[':
+			depth++
+		case b == '}' || b == ']':
+			depth--
+		}
+	}
+	if tooLarge {
+		return d.buf, start, ErrTooLarge
+	}
+	return d.buf, start, nil
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.offset++
+		return b, nil
+	}
+	if err != io.EOF {
+		d.err = fmt.Errorf("records: reading at byte %d: %w", d.offset, err)
+	}
+	return 0, err
+}
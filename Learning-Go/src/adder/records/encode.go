@@ -0,0 +1,203 @@
+package records
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/adder/jsontime"
+)
+
+type Format int
+
+const (
+	NDJSON Format = iota
+	JSONArray
+	CSV
+)
+
+func (f Format) String() string {
+	switch f {
+	case NDJSON:
+		return "ndjson"
+	case JSONArray:
+		return "json"
+	case CSV:
+		return "csv"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// WriterConfig controls how a Writer formats and flushes.
+type WriterConfig struct {
+	Format Format
+	// BufferSize is how much output is held before it is written on.
+	// Zero means 32 KB.
+	BufferSize int
+	// FlushEvery flushes after this many orders even if the buffer is
+	// not full, so a slow trickle of records still reaches the reader.
+	// Zero means only when the buffer fills and on Close.
+	FlushEvery int
+}
+
+// Writer writes Orders in one of the supported formats. It never holds
+// more than BufferSize bytes: once the buffer is full, Write blocks on
+// the underlying writer, so a slow consumer slows the producer down
+// rather than letting output pile up in memory.
+type Writer struct {
+	cfg     WriterConfig
+	dst     io.Writer
+	bw      *bufio.Writer
+	enc     *json.Encoder
+	csv     *csv.Writer
+	n       int
+	pending int
+	err     error
+}
+
+var csvHeader = []string{"order_id", "date_ordered", "customer_id", "item_id", "item_name"}
+
+func NewWriter(w io.Writer, cfg WriterConfig) *Writer {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 32 << 10
+	}
+	bw := bufio.NewWriterSize(w, cfg.BufferSize)
+	out := &Writer{cfg: cfg, dst: w, bw: bw}
+	switch cfg.Format {
+	case CSV:
+		out.csv = csv.NewWriter(bw)
+	default:
+		out.enc = json.NewEncoder(bw)
+	}
+	return out
+}
+
+// Write adds o to the output. After an error, every later call returns
+// the same error.
+func (w *Writer) Write(o Order) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.write(o)
+	if w.err != nil {
+		return w.err
+	}
+	w.n++
+	w.pending++
+	if w.cfg.FlushEvery > 0 && w.pending >= w.cfg.FlushEvery {
+		return w.Flush()
+	}
+	return nil
+}
+
+func (w *Writer) write(o Order) error {
+	switch w.cfg.Format {
+	case NDJSON:
+		return w.enc.Encode(o)
+	case JSONArray:
+		sep := ",\n"
+		if w.n == 0 {
+			sep = "[\n"
+		}
+		if _, err := w.bw.WriteString(sep); err != nil {
+			return err
+		}
+		b, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		_, err = w.bw.Write(b)
+		return err
+	case CSV:
+		if w.n == 0 {
+			if err := w.csv.Write(csvHeader); err != nil {
+				return err
+			}
+		}
+		row := []string{o.ID, jsontime.Default.Format(o.DateOrdered.Time), o.CustomerID, "", ""}
+		if len(o.Items) == 0 {
+			return w.csv.Write(row)
+		}
+		for _, it := range o.Items {
+			row[3], row[4] = it.ID, it.Name
+			if err := w.csv.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("records: unknown format %v", w.cfg.Format)
+}
+
+// Flush writes out everything buffered, then flushes the underlying
+// writer too if it can be, such as an http.ResponseWriter or a
+// gzip.Writer.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.csv != nil {
+		w.csv.Flush()
+		if w.err = w.csv.Error(); w.err != nil {
+			return w.err
+		}
+	}
+	if w.err = w.bw.Flush(); w.err != nil {
+		return w.err
+	}
+	w.pending = 0
+	switch f := w.dst.(type) {
+	case interface{ Flush() error }:
+		w.err = f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return w.err
+}
+
+// Close finishes the output, closing the array or writing the CSV
+// header if nothing else was written, and flushes it. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	switch {
+	case w.cfg.Format == JSONArray && w.n == 0:
+		_, w.err = w.bw.WriteString("[]\n")
+	case w.cfg.Format == JSONArray:
+		_, w.err = w.bw.WriteString("\n]\n")
+	case w.cfg.Format == CSV && w.n == 0:
+		w.err = w.csv.Write(csvHeader)
+	}
+	if w.err != nil {
+		return w.err
+	}
+	return w.Flush()
+}
+
+// Count returns how many orders have been written.
+func (w *Writer) Count() int {
+	return w.n
+}
+
+// Copy streams every order d returns into w, stopping at the first
+// error from either, and returns how many it wrote. It doesn't close w.
+func Copy(w *Writer, d *Decoder) (int, error) {
+	n := 0
+	for {
+		o, err := d.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := w.Write(o); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,68 @@
+// Package records streams Order records in and out as newline-delimited
+// JSON, a JSON array or CSV.
+package records
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/adder/jsontime"
+)
+
+type Item struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Order struct {
+	ID          string        `json:"id"`
+	DateOrdered jsontime.Time `json:"date_ordered"`
+	CustomerID  string        `json:"customer_id"`
+	Items       []Item        `json:"items"`
+}
+
+// ValidationError lists everything wrong with one record, by JSON field
+// name, such as items[2].id.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// validate checks that o has its required fields and between 1 and
+// maxItems items.
+func validate(o Order, maxItems int) error {
+	var problems []string
+	missing := func(field string) {
+		problems = append(problems, field+" is required")
+	}
+	if o.ID == "" {
+		missing("id")
+	}
+	if o.DateOrdered.IsZero() {
+		missing("date_ordered")
+	}
+	if o.CustomerID == "" {
+		missing("customer_id")
+	}
+	switch {
+	case len(o.Items) == 0:
+		problems = append(problems, "items must not be empty")
+	case len(o.Items) > maxItems:
+		problems = append(problems, fmt.Sprintf("items has %d entries, more than the limit of %d", len(o.Items), maxItems))
+	}
+	for i, it := range o.Items {
+		if it.ID == "" {
+			missing(fmt.Sprintf("items[%d].id", i))
+		}
+		if it.Name == "" {
+			missing(fmt.Sprintf("items[%d].name", i))
+		}
+	}
+	if problems != nil {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
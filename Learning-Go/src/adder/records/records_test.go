@@ -0,0 +1,214 @@
+package records
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const good = `{"id":"o1","date_ordered":"2020-05-01T10:00:00Z","customer_id":"c1","items":[{"id":"i1","name":"pen"}]}`
+
+func order(id string) string {
+	return strings.Replace(good, `"o1"`, `"`+id+`"`, 1)
+}
+
+// decodeAll returns each order's ID or each error's text, in order.
+func decodeAll(d *Decoder) []string {
+	var out []string
+	for {
+		o, err := d.Next()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			out = append(out, err.Error())
+			var rerr *RecordError
+			if !errors.As(err, &rerr) {
+				return out
+			}
+			continue
+		}
+		out = append(out, o.ID)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	noItems := `{"id":"o3","date_ordered":"2020-05-01T10:00:00Z","items":[]}`
+	badItem := `{"id":"o4","date_ordered":"2020-05-01T10:00:00Z","customer_id":"c1","items":[{"id":"i1"},{"name":"x"}]}`
+	data := []struct {
+		name  string
+		input string
+		cfg   Config
+		want  []string
+	}{
+		{"ndjson", order("a") + "\n" + order("b") + "\n", Config{}, []string{"a", "b"}},
+		{"concatenated", order("a") + order("b") + "  \r\n\n" + order("c"), Config{}, []string{"a", "b", "c"}},
+		{"braces_in_strings", `{"id":"}{\"","date_ordered":"2020-05-01T10:00:00Z","customer_id":"c","items":[{"id":"[","name":"{"}]}`,
+			Config{}, []string{`}{"`}},
+		{"validation", order("a") + "\n" + noItems + "\n" + badItem + "\n" + order("b"), Config{}, []string{
+			"a",
+			"record 1 at byte 103: customer_id is required; items must not be empty",
+			"record 2 at byte 164: items[0].name is required; items[1].id is required",
+			"b",
+		}},
+		{"not_object", order("a") + "\nnot json\n" + order("b"), Config{}, []string{
+			"a", "record 1 at byte 103: record is not a JSON object", "b",
+		}},
+		{"bad_json", order("a") + "\n" + `{"id": 7}` + "\n" + order("b"), Config{}, []string{
+			"a", "record 1 at byte 103: json: cannot unmarshal number into Go struct field Order.id of type string", "b",
+		}},
+		{"too_large", order("a") + "\n" + order(strings.Repeat("x", 200)) + "\n" + order("b"), Config{MaxRecordBytes: 150}, []string{
+			"a", "record 1 at byte 103: record exceeds the size limit", "b",
+		}},
+		{"too_many_items", strings.Replace(good, `}]`, `},{"id":"i2","name":"ink"}]`, 1), Config{MaxItems: 1}, []string{
+			"record 0 at byte 0: items has 2 entries, more than the limit of 1",
+		}},
+		{"truncated", order("a") + "\n" + good[:40], Config{}, []string{
+			"a", "record 1 at byte 103: unexpected EOF",
+		}},
+		{"skip", order("a") + "\nnot json\n" + noItems + "\n" + order("b"), Config{Skip: true}, []string{"a", "b"}},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			got := decodeAll(NewDecoder(strings.NewReader(d.input), d.cfg))
+			if strings.Join(got, "|") != strings.Join(d.want, "|") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(d.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	var dead bytes.Buffer
+	d := NewDecoder(strings.NewReader(order("a")+"\nnot json\n"+order("b")), Config{DeadLetter: &dead})
+	got := decodeAll(d)
+	if strings.Join(got, "|") != "a|b" {
+		t.Errorf("expected a|b, got %v", got)
+	}
+	if d.Skipped() != 1 {
+		t.Errorf("expected 1 skipped, got %d", d.Skipped())
+	}
+	want := `{"index":1,"offset":103,"error":"record is not a JSON object","record":"not json"}` + "\n"
+	if dead.String() != want {
+		t.Errorf("expected `%s`, got `%s`", want, dead.String())
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("disk on fire")
+}
+
+func TestReadErrorIsFinal(t *testing.T) {
+	d := NewDecoder(io.MultiReader(strings.NewReader(order("a")+"\n"), failingReader{}), Config{Skip: true})
+	if _, err := d.Next(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := d.Next()
+		if err == nil || err.Error() != "records: reading at byte 103: disk on fire" {
+			t.Errorf("expected the read error, got %v", err)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	two := order("a") + "\n" + strings.Replace(order("b"), `}]`, `},{"id":"i2","name":"ink, blue"}]`, 1)
+	data := []struct {
+		format Format
+		input  string
+		want   string
+	}{
+		{NDJSON, two, order("a") + "\n" + strings.Replace(order("b"), `}]`, `},{"id":"i2","name":"ink, blue"}]`, 1) + "\n"},
+		{JSONArray, two, "[\n" + order("a") + ",\n" + strings.Replace(order("b"), `}]`, `},{"id":"i2","name":"ink, blue"}]`, 1) + "\n]\n"},
+		{JSONArray, "", "[]\n"},
+		{CSV, two, "order_id,date_ordered,customer_id,item_id,item_name\n" +
+			"a,2020-05-01T10:00:00Z,c1,i1,pen\n" +
+			"b,2020-05-01T10:00:00Z,c1,i1,pen\n" +
+			"b,2020-05-01T10:00:00Z,c1,i2,\"ink, blue\"\n"},
+		{CSV, "", "order_id,date_ordered,customer_id,item_id,item_name\n"},
+		{CSV, strings.Replace(order("u"), `"2020-05-01T10:00:00Z"`, `1588327200`, 1) + "\n" +
+			strings.Replace(order("z"), `"2020-05-01T10:00:00Z"`, `"01 May 20 12:00 +0200"`, 1),
+			"order_id,date_ordered,customer_id,item_id,item_name\n" +
+				"u,2020-05-01T10:00:00Z,c1,i1,pen\n" +
+				"z,2020-05-01T10:00:00Z,c1,i1,pen\n"},
+		{NDJSON, strings.Replace(order("m"), `10:00:00Z`, `10:00:00.125Z`, 1),
+			strings.Replace(order("m"), `10:00:00Z`, `10:00:00.125Z`, 1) + "\n"},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%s_%d", d.format, len(d.input)), func(t *testing.T) {
+			var b bytes.Buffer
+			w := NewWriter(&b, WriterConfig{Format: d.format})
+			n, err := Copy(w, NewDecoder(strings.NewReader(d.input), Config{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != w.Count() {
+				t.Errorf("expected %d written, got %d", n, w.Count())
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != d.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", d.want, b.String())
+			}
+		})
+	}
+}
+
+// flushRecorder counts flushes and notes how much had arrived at each.
+type flushRecorder struct {
+	bytes.Buffer
+	flushes []int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes = append(f.flushes, f.Len())
+}
+
+func TestFlushEvery(t *testing.T) {
+	var dst flushRecorder
+	w := NewWriter(&dst, WriterConfig{Format: NDJSON, FlushEvery: 2})
+	d := NewDecoder(strings.NewReader(order("a")+order("b")+order("c")), Config{})
+	if _, err := Copy(w, d); err != nil {
+		t.Fatal(err)
+	}
+	line := len(order("a")) + 1
+	if fmt.Sprint(dst.flushes) != fmt.Sprint([]int{2 * line}) {
+		t.Errorf("expected one flush after two orders, got %v", dst.flushes)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(dst.flushes) != fmt.Sprint([]int{2 * line, 3 * line}) {
+		t.Errorf("expected a final flush on Close, got %v", dst.flushes)
+	}
+}
+
+// slowWriter accepts at most 16 bytes per call, like a congested socket.
+type slowWriter struct {
+	bytes.Buffer
+}
+
+func (s *slowWriter) Write(p []byte) (int, error) {
+	if len(p) > 16 {
+		p = p[:16]
+	}
+	s.Buffer.Write(p)
+	return len(p), io.ErrShortWrite
+}
+
+func TestWriteErrorSticks(t *testing.T) {
+	w := NewWriter(&slowWriter{}, WriterConfig{Format: NDJSON, BufferSize: 64})
+	d := NewDecoder(strings.NewReader(order("a")+order("b")), Config{})
+	if _, err := Copy(w, d); err != io.ErrShortWrite {
+		t.Fatalf("expected %v, got %v", io.ErrShortWrite, err)
+	}
+	if err := w.Close(); err != io.ErrShortWrite {
+		t.Errorf("expected Close to report %v, got %v", io.ErrShortWrite, err)
+	}
+}
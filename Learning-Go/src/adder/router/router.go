@@ -0,0 +1,395 @@
+// Package router matches requests on method and path patterns with
+// parameters, such as GET /users/{id}/pets/{petID}.
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Router dispatches to the most specific route whose pattern matches
+// the request path. A pattern is a sequence of /-separated segments;
+// {name} matches any one segment and a final {name...} matches the rest
+// of the path. Literal segments win over {name}, which wins over
+// {name...}.
+type Router struct {
+	// NotFound handles requests no route matches. Nil means
+	// http.NotFound.
+	NotFound http.Handler
+	// OnMatch, if set, is called with the full pattern of the route a
+	// request matched, before its handler runs, and of the route that
+	// answered 405 for it. Only the router that receives the request
+	// calls it; mounted routers' are ignored. metrics.SetRoute fits.
+	OnMatch func(ctx context.Context, pattern string)
+
+	middleware []func(http.Handler) http.Handler
+	routes     []*route
+	mounts     []*mount
+}
+
+type route struct {
+	pattern  string
+	segments []segment
+	methods  map[string]http.Handler
+}
+
+type mount struct {
+	prefix   string
+	segments []segment
+	sub      *Router
+}
+
+type segment struct {
+	literal string
+	param   string
+	rest    bool
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+// Use adds middleware that runs, in the order added, around every
+// handler r dispatches to, including mounted routers. Requests r itself
+// answers with a 404 or 405 skip it; those a mounted router rejects do
+// not.
+func (r *Router) Use(mw ...func(http.Handler) http.Handler) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Handle registers h for method and pattern, wrapped in mw with the
+// first one outermost. It panics on a malformed or duplicate pattern,
+// like http.ServeMux.
+func (r *Router) Handle(method, pattern string, h http.Handler, mw ...func(http.Handler) http.Handler) {
+	segs, err := parse(pattern)
+	if err != nil {
+		panic(err)
+	}
+	h = wrap(h, mw)
+	// Patterns that differ only in parameter names match the same paths,
+	// so the later one could never be reached.
+	sh := shape(segs)
+	for _, rt := range r.routes {
+		if shape(rt.segments) != sh {
+			continue
+		}
+		if _, ok := rt.methods[method]; ok {
+			if rt.pattern == pattern {
+				panic(fmt.Sprintf("router: %s %s registered twice", method, pattern))
+			}
+			panic(fmt.Sprintf("router: %s %s conflicts with %s %s", method, pattern, method, rt.pattern))
+		}
+		if rt.pattern == pattern {
+			rt.methods[method] = h
+			return
+		}
+	}
+	r.routes = append(r.routes, &route{pattern: pattern, segments: segs, methods: map[string]http.Handler{method: h}})
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return moreSpecific(r.routes[i].segments, r.routes[j].segments)
+	})
+}
+
+func wrap(h http.Handler, mw []func(http.Handler) http.Handler) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+func (r *Router) HandleFunc(method, pattern string, f http.HandlerFunc, mw ...func(http.Handler) http.Handler) {
+	r.Handle(method, pattern, f, mw...)
+}
+
+// Mount serves sub under prefix, which may contain parameters but not
+// {name...}. sub's routes are matched against the rest of the path, and
+// their patterns are reported with prefix in front.
+func (r *Router) Mount(prefix string, sub *Router) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	var segs []segment
+	if prefix != "" {
+		// Mounting at / leaves no segments to match, so sub sees the
+		// whole path.
+		var err error
+		if segs, err = parse(prefix); err != nil {
+			panic(err)
+		}
+	}
+	for _, s := range segs {
+		if s.rest {
+			panic("router: mount prefix " + prefix + " cannot end in {name...}")
+		}
+	}
+	r.mounts = append(r.mounts, &mount{prefix: prefix, segments: segs, sub: sub})
+	sort.SliceStable(r.mounts, func(i, j int) bool {
+		return len(r.mounts[i].segments) > len(r.mounts[j].segments)
+	})
+}
+
+func parse(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("router: pattern %q must start with /", pattern)
+	}
+	var segs []segment
+	seen := map[string]bool{}
+	parts := strings.Split(pattern[1:], "/")
+	for i, p := range parts {
+		if !strings.HasPrefix(p, "{") || !strings.HasSuffix(p, "}") {
+			if strings.ContainsAny(p, "{}") {
+				return nil, fmt.Errorf("router: pattern %q: a parameter must be a whole segment", pattern)
+			}
+			segs = append(segs, segment{literal: p})
+			continue
+		}
+		name := p[1 : len(p)-1]
+		rest := strings.HasSuffix(name, "...")
+		name = strings.TrimSuffix(name, "...")
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("router: pattern %q has an unnamed parameter", pattern)
+		case seen[name]:
+			return nil, fmt.Errorf("router: pattern %q uses {%s} twice", pattern, name)
+		case rest && i != len(parts)-1:
+			return nil, fmt.Errorf("router: pattern %q: {%s...} must be last", pattern, name)
+		}
+		seen[name] = true
+		segs = append(segs, segment{param: name, rest: rest})
+	}
+	return segs, nil
+}
+
+// shape describes the paths segs matches, ignoring parameter names.
+func shape(segs []segment) string {
+	var b strings.Builder
+	for _, s := range segs {
+		switch {
+		case s.rest:
+			b.WriteString("/{...}")
+		case s.param != "":
+			b.WriteString("/{}")
+		default:
+			b.WriteString("/" + s.literal)
+		}
+	}
+	return b.String()
+}
+
+func (s segment) rank() int {
+	switch {
+	case s.rest:
+		return 2
+	case s.param != "":
+		return 1
+	}
+	return 0
+}
+
+func moreSpecific(a, b []segment) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if ra, rb := a[i].rank(), b[i].rank(); ra != rb {
+			return ra < rb
+		}
+	}
+	return len(a) > len(b)
+}
+
+// match reports whether segs matches the start of path (all of it if
+// whole is set), returning the parameters and the unmatched remainder.
+func match(segs []segment, path []string, whole bool) ([]Param, []string, bool) {
+	var params []Param
+	for i, s := range segs {
+		if s.rest {
+			return append(params, Param{s.param, strings.Join(path[i:], "/")}), nil, true
+		}
+		if i >= len(path) {
+			return nil, nil, false
+		}
+		if s.param != "" {
+			if path[i] == "" {
+				return nil, nil, false
+			}
+			params = append(params, Param{s.param, path[i]})
+		} else if s.literal != path[i] {
+			return nil, nil, false
+		}
+	}
+	rest := path[len(segs):]
+	if whole && len(rest) > 0 {
+		return nil, nil, false
+	}
+	return params, rest, true
+}
+
+// Param is one path parameter from a matched route.
+type Param struct {
+	Name  string
+	Value string
+}
+
+type matched struct {
+	params  []Param
+	pattern string
+}
+
+type matchKey struct{}
+
+func fromContext(ctx context.Context) *matched {
+	m, _ := ctx.Value(matchKey{}).(*matched)
+	return m
+}
+
+// URLParam returns the value of the {name} parameter of the route that
+// matched the request in ctx, or "" if there is none.
+func URLParam(ctx context.Context, name string) string {
+	if m := fromContext(ctx); m != nil {
+		for _, p := range m.params {
+			if p.Name == name {
+				return p.Value
+			}
+		}
+	}
+	return ""
+}
+
+// URLParams returns every parameter of the matched route in pattern
+// order.
+func URLParams(ctx context.Context) []Param {
+	if m := fromContext(ctx); m != nil {
+		return append([]Param(nil), m.params...)
+	}
+	return nil
+}
+
+// Pattern returns the full pattern of the route that matched the request
+// in ctx, including any mount prefixes, such as /api/users/{id}.
+func Pattern(ctx context.Context) string {
+	if m := fromContext(ctx); m != nil {
+		return m.pattern
+	}
+	return ""
+}
+
+func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.EscapedPath(), "/"), "/")
+	for i, p := range parts {
+		if u, err := url.PathUnescape(p); err == nil {
+			parts[i] = u
+		}
+	}
+	r.serve(rw, req, parts, &matched{}, r.OnMatch)
+}
+
+func (r *Router) serve(rw http.ResponseWriter, req *http.Request, path []string, prev *matched, onMatch func(context.Context, string)) {
+	var allowed []string
+	var allowedPattern string
+	for _, rt := range r.routes {
+		params, _, ok := match(rt.segments, path, true)
+		if !ok {
+			continue
+		}
+		h, ok := rt.methods[req.Method]
+		if !ok && req.Method == http.MethodHead {
+			h, ok = rt.methods[http.MethodGet]
+		}
+		if !ok {
+			for m := range rt.methods {
+				allowed = append(allowed, m)
+			}
+			if allowedPattern == "" {
+				allowedPattern = rt.pattern
+			}
+			continue
+		}
+		m := &matched{
+			params:  append(append([]Param(nil), prev.params...), params...),
+			pattern: prev.pattern + rt.pattern,
+		}
+		ctx := context.WithValue(req.Context(), matchKey{}, m)
+		if onMatch != nil {
+			onMatch(ctx, m.pattern)
+		}
+		wrap(h, r.middleware).ServeHTTP(rw, req.WithContext(ctx))
+		return
+	}
+	if allowed != nil {
+		if onMatch != nil {
+			onMatch(req.Context(), prev.pattern+allowedPattern)
+		}
+		rw.Header().Set("Allow", allowHeader(allowed))
+		if req.Method == http.MethodOptions {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	for _, mt := range r.mounts {
+		params, rest, ok := match(mt.segments, path, false)
+		if !ok || len(rest) == 0 {
+			continue
+		}
+		m := &matched{
+			params:  append(append([]Param(nil), prev.params...), params...),
+			pattern: prev.pattern + mt.prefix,
+		}
+		sub := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			mt.sub.serve(rw, req, rest, m, onMatch)
+		})
+		wrap(sub, r.middleware).ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), matchKey{}, m)))
+		return
+	}
+	if r.NotFound != nil {
+		r.NotFound.ServeHTTP(rw, req)
+		return
+	}
+	http.NotFound(rw, req)
+}
+
+func allowHeader(methods []string) string {
+	set := map[string]bool{http.MethodOptions: true}
+	for _, m := range methods {
+		set[m] = true
+		if m == http.MethodGet {
+			set[http.MethodHead] = true
+		}
+	}
+	out := make([]string, 0, len(set))
+	for m := range set {
+		out = append(out, m)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
+
+// Route describes one registered method and pattern.
+type Route struct {
+	Method  string
+	Pattern string
+}
+
+// Routes lists every route on r and its mounted routers, sorted by
+// pattern and method.
+func (r *Router) Routes() []Route {
+	var out []Route
+	for _, rt := range r.routes {
+		for m := range rt.methods {
+			out = append(out, Route{m, rt.pattern})
+		}
+	}
+	for _, mt := range r.mounts {
+		for _, sub := range mt.sub.Routes() {
+			out = append(out, Route{sub.Method, mt.prefix + sub.Pattern})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Pattern != out[j].Pattern {
+			return out[i].Pattern < out[j].Pattern
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
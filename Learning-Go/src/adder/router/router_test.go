@@ -0,0 +1,193 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/adder/metrics"
+)
+
+// echo writes the handler name, matched pattern and parameters.
+func echo(name string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		var params []string
+		for _, p := range URLParams(req.Context()) {
+			params = append(params, p.Name+"="+p.Value)
+		}
+		fmt.Fprintf(rw, "%s %s %s", name, Pattern(req.Context()), strings.Join(params, ","))
+	}
+}
+
+func tag(label string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Add("X-Middleware", label)
+			h.ServeHTTP(rw, req)
+		})
+	}
+}
+
+func newTestRouter() *Router {
+	pets := New()
+	pets.Use(tag("pets"))
+	pets.HandleFunc(http.MethodGet, "/", echo("list pets"))
+	pets.HandleFunc(http.MethodGet, "/{petID}", echo("get pet"))
+
+	r := New()
+	r.Use(tag("root"))
+	r.HandleFunc(http.MethodGet, "/users/{id}", echo("get user"))
+	r.HandleFunc(http.MethodDelete, "/users/{id}", echo("delete user"), tag("admin"))
+	r.HandleFunc(http.MethodGet, "/users/me", echo("me"))
+	r.HandleFunc(http.MethodPost, "/users/new", echo("create user"))
+	r.HandleFunc(http.MethodGet, "/users/{id}/pets/{petID}", echo("user pet"))
+	r.HandleFunc(http.MethodGet, "/files/{path...}", echo("file"))
+	r.Mount("/users/{id}/kennel", pets)
+	return r
+}
+
+func TestRouting(t *testing.T) {
+	r := newTestRouter()
+	data := []struct {
+		method     string
+		path       string
+		code       int
+		body       string
+		middleware string
+		allow      string
+	}{
+		{"GET", "/users/42", 200, "get user /users/{id} id=42", "root", ""},
+		{"DELETE", "/users/42", 200, "delete user /users/{id} id=42", "root,admin", ""},
+		{"GET", "/users/me", 200, "me /users/me ", "root", ""},
+		{"GET", "/users/new", 200, "get user /users/{id} id=new", "root", ""},
+		{"POST", "/users/new", 200, "create user /users/new ", "root", ""},
+		{"HEAD", "/users/42", 200, "", "root", ""},
+		{"GET", "/users/42/pets/7", 200, "user pet /users/{id}/pets/{petID} id=42,petID=7", "root", ""},
+		{"GET", "/users/a%2Fb", 200, "get user /users/{id} id=a/b", "root", ""},
+		{"GET", "/files/a/b/c.txt", 200, "file /files/{path...} path=a/b/c.txt", "root", ""},
+		{"GET", "/users/42/kennel/", 200, "list pets /users/{id}/kennel/ id=42", "root,pets", ""},
+		{"GET", "/users/42/kennel/9", 200, "get pet /users/{id}/kennel/{petID} id=42,petID=9", "root,pets", ""},
+		{"PUT", "/users/42", 405, "Method Not Allowed\n", "", "DELETE, GET, HEAD, OPTIONS"},
+		{"PUT", "/users/new", 405, "Method Not Allowed\n", "", "DELETE, GET, HEAD, OPTIONS, POST"},
+		{"OPTIONS", "/users/42", 204, "", "", "DELETE, GET, HEAD, OPTIONS"},
+		{"GET", "/users", 404, "404 page not found\n", "", ""},
+		{"GET", "/users/", 404, "404 page not found\n", "", ""},
+		{"GET", "/users/42/kennel/9/toys", 404, "404 page not found\n", "root", ""},
+	}
+	for _, d := range data {
+		t.Run(d.method+" "+d.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(d.method, d.path, nil))
+			if rec.Code != d.code {
+				t.Errorf("expected %d, got %d", d.code, rec.Code)
+			}
+			if d.method != "HEAD" && rec.Body.String() != d.body {
+				t.Errorf("expected body `%s`, got `%s`", d.body, rec.Body.String())
+			}
+			if got := strings.Join(rec.Header()["X-Middleware"], ","); got != d.middleware {
+				t.Errorf("expected middleware `%s`, got `%s`", d.middleware, got)
+			}
+			if got := rec.Header().Get("Allow"); got != d.allow {
+				t.Errorf("expected Allow `%s`, got `%s`", d.allow, got)
+			}
+		})
+	}
+}
+
+func TestBadPatterns(t *testing.T) {
+	data := []string{"users", "/users/{}", "/users/{id}/{id}", "/files/{path...}/x", "/users/x{id}"}
+	for _, pattern := range data {
+		t.Run(pattern, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected %q to panic", pattern)
+				}
+			}()
+			New().HandleFunc(http.MethodGet, pattern, echo(""))
+		})
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	data := []struct {
+		name          string
+		first, second string
+		method2       string
+		wantPanic     bool
+	}{
+		{"twice", "/u/{id}", "/u/{id}", http.MethodGet, true},
+		{"renamed", "/u/{id}", "/u/{x}", http.MethodGet, true},
+		{"renamed_rest", "/f/{path...}", "/f/{p...}", http.MethodGet, true},
+		{"other_method", "/u/{id}", "/u/{x}", http.MethodDelete, false},
+		{"literal", "/u/{id}", "/u/me", http.MethodGet, false},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			defer func() {
+				if got := recover() != nil; got != d.wantPanic {
+					t.Errorf("expected panic %v, got %v", d.wantPanic, got)
+				}
+			}()
+			r := New()
+			r.HandleFunc(http.MethodGet, d.first, echo(""))
+			r.HandleFunc(d.method2, d.second, echo(""))
+		})
+	}
+}
+
+func TestRootMount(t *testing.T) {
+	api := New()
+	api.HandleFunc(http.MethodGet, "/users/{id}", echo("get user"))
+	r := New()
+	r.HandleFunc(http.MethodGet, "/health", echo("health"))
+	r.Mount("/", api)
+	for path, want := range map[string]string{
+		"/health":   "health /health ",
+		"/users/42": "get user /users/{id} id=42",
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Body.String() != want {
+			t.Errorf("expected `%s`, got `%s`", want, rec.Body.String())
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	var got []string
+	for _, rt := range newTestRouter().Routes() {
+		got = append(got, rt.Method+" "+rt.Pattern)
+	}
+	want := "GET /files/{path...}|GET /users/me|POST /users/new|DELETE /users/{id}|GET /users/{id}|" +
+		"GET /users/{id}/kennel/|GET /users/{id}/kennel/{petID}|GET /users/{id}/pets/{petID}"
+	if strings.Join(got, "|") != want {
+		t.Errorf("expected %s, got %s", want, strings.Join(got, "|"))
+	}
+}
+
+func TestMetricsLabel(t *testing.T) {
+	m := metrics.New(metrics.Config{})
+	rt := newTestRouter()
+	rt.OnMatch = metrics.SetRoute
+	h := m.Middleware(rt)
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/users/42/kennel/9"},
+		{http.MethodPut, "/users/42"},
+		{http.MethodGet, "/nowhere"},
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(r.method, r.path, nil))
+	}
+	var b strings.Builder
+	m.WriteTo(&b)
+	for _, want := range []string{
+		`http_requests_total{route="/users/{id}/kennel/{petID}",method="GET",code="2xx"} 1`,
+		`http_requests_total{route="/users/{id}",method="PUT",code="4xx"} 1`,
+		`http_requests_total{route="unmatched",method="GET",code="4xx"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected `%s`, got:\n%s", want, b.String())
+		}
+	}
+}
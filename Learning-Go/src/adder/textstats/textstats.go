@@ -0,0 +1,383 @@
+// Package textstats computes letter, word, sentence and character n-gram
+// statistics over UTF-8 text of any size.
+package textstats
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Config struct {
+	// Fold case-folds letters, words and n-grams, so "Go", "GO" and "go"
+	// are counted together.
+	Fold bool
+	// CharN is the length of the character n-grams taken from each word.
+	// Zero disables them.
+	CharN int
+}
+
+// Stats is the result of counting. Letters, words and n-grams are keyed
+// by their text, folded if Config.Fold is set.
+type Stats struct {
+	Bytes        int64
+	Runes        int64
+	InvalidBytes int64
+	Letters      map[string]int
+	// Categories counts every rune by Unicode category: letters by
+	// subcategory (Lu, Ll, Lt, Lm, Lo), everything else by major
+	// category (M, N, P, S, Z, C).
+	Categories map[string]int
+	Words      map[string]int
+	CharNGrams map[string]int
+	Sentences  int
+	// SentenceLengths maps a sentence length in words to how many
+	// sentences had it.
+	SentenceLengths map[int]int
+}
+
+func newStats() *Stats {
+	return &Stats{
+		Letters:         map[string]int{},
+		Categories:      map[string]int{},
+		Words:           map[string]int{},
+		CharNGrams:      map[string]int{},
+		SentenceLengths: map[int]int{},
+	}
+}
+
+// Counter accumulates statistics from the bytes written to it. Writes
+// may split UTF-8 sequences, words and sentences anywhere.
+//
+// Counters are mergeable: split an input into consecutive parts, count
+// each with its own Counter, and Merge them in input order to get the
+// same Stats as counting the whole input with one Counter. To make that
+// work, a Counter holds back the bytes before the first whitespace it
+// sees, since it cannot tell how they join onto the previous part.
+type Counter struct {
+	cfg   Config
+	stats *Stats
+
+	// head is the raw input up to and including the first whitespace.
+	// Nothing in it has been counted yet.
+	head     []byte
+	headDone bool
+	// pending is an incomplete UTF-8 sequence at the end of the last
+	// Write.
+	pending []byte
+
+	word        []rune
+	pendingTerm bool
+	curWords    int
+	// closed and leadWords describe the first sentence end seen after
+	// head, which Merge needs to join sentences across parts.
+	closed    bool
+	leadWords int
+}
+
+func NewCounter(cfg Config) *Counter {
+	return &Counter{cfg: cfg, stats: newStats()}
+}
+
+func (c *Counter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(c.pending) > 0 {
+		p = append(c.pending, p...)
+		c.pending = nil
+	}
+	for len(p) > 0 {
+		if !utf8.FullRune(p) {
+			c.pending = append([]byte(nil), p...)
+			break
+		}
+		r, size := utf8.DecodeRune(p)
+		if !c.headDone {
+			c.head = append(c.head, p[:size]...)
+			if isSafePoint(r) {
+				c.headDone = true
+			}
+		} else {
+			c.rune(r, size)
+		}
+		p = p[size:]
+	}
+	return n, nil
+}
+
+// ReadFrom counts everything r returns until io.EOF.
+func (c *Counter) ReadFrom(r io.Reader) (int64, error) {
+	buf := make([]byte, 32*1024)
+	var total int64
+	for {
+		n, err := r.Read(buf)
+		c.Write(buf[:n])
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// isSafePoint reports whether the counter's state after r no longer
+// depends on anything before it, apart from the open sentence.
+func isSafePoint(r rune) bool {
+	return unicode.IsSpace(r) || isIdeographicTerminator(r)
+}
+
+func isIdeographicTerminator(r rune) bool {
+	return r == '。' || r == '！' || r == '？'
+}
+
+func isTerminator(r rune) bool {
+	return strings.ContainsRune(".!?…‼⁇⁈⁉", r)
+}
+
+// isClosing reports whether r may sit between a terminator and the
+// whitespace that ends the sentence, as in `"Stop!" he said`.
+func isClosing(r rune) bool {
+	return r == '"' || r == '\'' || r == '’' || r == '”' ||
+		unicode.In(r, unicode.Pe, unicode.Pf)
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// isIdeograph reports whether r is a word of its own, since the scripts
+// it comes from do not separate words with spaces.
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+func fold(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+func category(r rune) string {
+	for _, cat := range []string{"Lu", "Ll", "Lt", "Lm", "Lo", "M", "N", "P", "S", "Z", "C"} {
+		if unicode.Is(unicode.Categories[cat], r) {
+			return cat
+		}
+	}
+	return "C"
+}
+
+func (c *Counter) rune(r rune, size int) {
+	s := c.stats
+	s.Bytes += int64(size)
+	if r == utf8.RuneError && size == 1 {
+		s.InvalidBytes++
+		c.endWord()
+		c.pendingTerm = false
+		return
+	}
+	s.Runes++
+	s.Categories[category(r)]++
+	if c.cfg.Fold {
+		r = fold(r)
+	}
+	if unicode.IsLetter(r) {
+		s.Letters[string(r)]++
+	}
+
+	switch {
+	case isIdeograph(r):
+		c.endWord()
+		c.word = append(c.word, r)
+		c.endWord()
+		c.pendingTerm = false
+	case isWordRune(r):
+		c.word = append(c.word, r)
+		c.pendingTerm = false
+	case isApostrophe(r) && len(c.word) > 0:
+		c.word = append(c.word, r)
+	case isIdeographicTerminator(r):
+		c.endWord()
+		c.endSentence()
+	case isTerminator(r):
+		c.endWord()
+		c.pendingTerm = true
+	case unicode.IsSpace(r):
+		c.endWord()
+		if c.pendingTerm {
+			c.endSentence()
+		}
+	case isClosing(r):
+		c.endWord()
+	default:
+		c.endWord()
+		c.pendingTerm = false
+	}
+}
+
+func (c *Counter) endWord() {
+	for len(c.word) > 0 && isApostrophe(c.word[len(c.word)-1]) {
+		c.word = c.word[:len(c.word)-1]
+	}
+	if len(c.word) == 0 {
+		return
+	}
+	c.stats.Words[string(c.word)]++
+	if n := c.cfg.CharN; n > 0 {
+		for i := 0; i+n <= len(c.word); i++ {
+			c.stats.CharNGrams[string(c.word[i:i+n])]++
+		}
+	}
+	c.curWords++
+	c.word = c.word[:0]
+}
+
+func (c *Counter) endSentence() {
+	c.pendingTerm = false
+	if !c.closed {
+		c.closed = true
+		c.leadWords = c.curWords
+	}
+	c.addSentence(c.curWords)
+	c.curWords = 0
+}
+
+func (c *Counter) addSentence(words int) {
+	if words > 0 {
+		c.stats.Sentences++
+		c.stats.SentenceLengths[words]++
+	}
+}
+
+// Merge folds next, which must have counted the input immediately
+// following c's, into c. next must not be used afterwards.
+func (c *Counter) Merge(next *Counter) {
+	c.Write(next.head)
+	if !next.headDone {
+		c.Write(next.pending)
+		return
+	}
+	s, ns := c.stats, next.stats
+	s.Bytes += ns.Bytes
+	s.Runes += ns.Runes
+	s.InvalidBytes += ns.InvalidBytes
+	addCounts(s.Letters, ns.Letters)
+	addCounts(s.Categories, ns.Categories)
+	addCounts(s.Words, ns.Words)
+	addCounts(s.CharNGrams, ns.CharNGrams)
+	s.Sentences += ns.Sentences
+	for k, v := range ns.SentenceLengths {
+		s.SentenceLengths[k] += v
+	}
+
+	if next.closed {
+		// next counted its first sentence without the words c had
+		// already seen; recount it at its full length.
+		total := c.curWords + next.leadWords
+		if next.leadWords > 0 {
+			s.Sentences--
+			if s.SentenceLengths[next.leadWords]--; s.SentenceLengths[next.leadWords] == 0 {
+				delete(s.SentenceLengths, next.leadWords)
+			}
+		}
+		if !c.closed {
+			c.closed = true
+			c.leadWords = total
+		}
+		c.addSentence(total)
+		c.curWords = next.curWords
+	} else {
+		c.curWords += next.curWords
+	}
+	c.word = append(c.word[:0], next.word...)
+	c.pendingTerm = next.pendingTerm
+	c.pending = next.pending
+}
+
+func addCounts(dst, src map[string]int) {
+	for k, v := range src {
+		dst[k] += v
+	}
+}
+
+// Stats finishes counting and returns the result. The Counter must not
+// be written to or merged afterwards.
+func (c *Counter) Stats() *Stats {
+	start := &Counter{cfg: c.cfg, stats: newStats(), headDone: true}
+	start.Merge(c)
+	for range start.pending {
+		start.rune(utf8.RuneError, 1)
+	}
+	start.pending = nil
+	start.endWord()
+	start.addSentence(start.curWords)
+	return start.stats
+}
+
+// Count reads r to the end and returns its statistics.
+func Count(r io.Reader, cfg Config) (*Stats, error) {
+	c := NewCounter(cfg)
+	if _, err := c.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return c.Stats(), nil
+}
+
+// CountParallel splits the first size bytes of r into parts, counts them
+// concurrently, and merges the results.
+func CountParallel(r io.ReaderAt, size int64, parts int, cfg Config) (*Stats, error) {
+	if parts < 1 {
+		parts = 1
+	}
+	counters := make([]*Counter, parts)
+	errs := make([]error, parts)
+	var wg sync.WaitGroup
+	for i := range counters {
+		start, end := size*int64(i)/int64(parts), size*int64(i+1)/int64(parts)
+		counters[i] = NewCounter(cfg)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = counters[i].ReadFrom(io.NewSectionReader(r, start, end-start))
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, next := range counters[1:] {
+		counters[0].Merge(next)
+	}
+	return counters[0].Stats(), nil
+}
+
+type Entry struct {
+	Key   string
+	Count int
+}
+
+// Top returns the k most frequent keys of counts, most frequent first,
+// with ties broken alphabetically. k <= 0 returns them all.
+func Top(counts map[string]int, k int) []Entry {
+	out := make([]Entry, 0, len(counts))
+	for key, n := range counts {
+		out = append(out, Entry{key, n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	if k > 0 && k < len(out) {
+		out = out[:k]
+	}
+	return out
+}
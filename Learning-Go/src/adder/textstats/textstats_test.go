@@ -0,0 +1,143 @@
+package textstats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const sample = "Go is fun. GO IS FAST! Don't panic… \"Really?\" she said.\n" +
+	"Ça coûte 3,50 €; naïve café e\u0301te. Σίσυφος ΣΊΣΥΦΟΣ.\n" +
+	"日本語の文章。二つ目！ Then 3.14 stays one sentence"
+
+// oneByteReader hands out a single byte per Read, so every multi-byte
+// rune is split across reads.
+type oneByteReader struct {
+	r *strings.Reader
+}
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	return o.r.Read(p[:1])
+}
+
+func TestCount(t *testing.T) {
+	s, err := Count(oneByteReader{strings.NewReader(sample)}, Config{Fold: true, CharN: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"word go", s.Words["go"], 2},
+		{"word don't", s.Words["don't"], 1},
+		{"word ça", s.Words["ça"], 1},
+		{"word combining", s.Words["e\u0301te"], 1},
+		{"word final sigma folded", s.Words["σίσυφοσ"], 2},
+		{"ideograph", s.Words["日"], 1},
+		{"number", s.Words["3"], 2},
+		{"letter é", s.Letters["é"], 1},
+		{"letter ç", s.Letters["ç"], 1},
+		{"letter Ç unfolded", s.Letters["Ç"], 0},
+		{"bigram go", s.CharNGrams["go"], 2},
+		{"sentences", s.Sentences, 10},
+		{"currency symbol", s.Categories["S"], 1},
+		{"combining mark", s.Categories["M"], 1},
+		{"kana and han as Lo", s.Categories["Lo"], 9},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			if d.got != d.want {
+				t.Errorf("expected %d, got %d", d.want, d.got)
+			}
+		})
+	}
+	if s.Runes != int64(len([]rune(sample))) || s.Bytes != int64(len(sample)) || s.InvalidBytes != 0 {
+		t.Errorf("unexpected totals %d runes, %d bytes, %d invalid", s.Runes, s.Bytes, s.InvalidBytes)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	s, err := Count(strings.NewReader("ab\xffcd \xe6\x97"), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.InvalidBytes != 3 || s.Words["ab"] != 1 || s.Words["cd"] != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	cfg := Config{Fold: true, CharN: 3}
+	want, err := Count(strings.NewReader(sample), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every split point, including ones inside runes, words, sentences
+	// and the whitespace between them.
+	for i := 0; i <= len(sample); i++ {
+		for _, j := range []int{i, i + 1, i + 7} {
+			if j > len(sample) {
+				continue
+			}
+			parts := []string{sample[:i], sample[i:j], sample[j:]}
+			c := NewCounter(cfg)
+			c.Write([]byte(parts[0]))
+			for _, p := range parts[1:] {
+				next := NewCounter(cfg)
+				next.Write([]byte(p))
+				c.Merge(next)
+			}
+			if diff := cmp.Diff(want, c.Stats()); diff != "" {
+				t.Fatalf("split at %d and %d (-want +got):\n%s", i, j, diff)
+			}
+		}
+	}
+}
+
+func TestCountParallel(t *testing.T) {
+	var buf bytes.Buffer
+	for i := 0; i < 200; i++ {
+		buf.WriteString(sample)
+		buf.WriteString("\n")
+	}
+	cfg := Config{Fold: true, CharN: 2}
+	want, _ := Count(bytes.NewReader(buf.Bytes()), cfg)
+	got, err := CountParallel(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 7, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func TestGzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(sample))
+	zw.Close()
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Count(zr, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Words["GO"] != 1 || s.Words["Go"] != 1 {
+		t.Errorf("expected unfolded words, got %v", s.Words)
+	}
+}
+
+func TestTop(t *testing.T) {
+	got := Top(map[string]int{"b": 2, "a": 2, "c": 5, "d": 1}, 3)
+	want := []Entry{{"c", 5}, {"a", 2}, {"b", 2}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,107 @@
+// Package audit records who did what, in a tamper-evident log.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/statuswriter"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/tracker"
+)
+
+var now = time.Now
+
+// Middleware appends a Record to l for every request. It needs to sit
+// inside identity.Middleware and tracker.Middleware to see the user and
+// GUID. A failed write is logged; the response has gone out by then.
+func (l *Log) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		start := now()
+		var body *hashingBody
+		if mutating(req.Method) && req.Body != nil {
+			body = &hashingBody{ReadCloser: req.Body, h: sha256.New()}
+			req.Body = body
+		}
+		sw, wrapped := statuswriter.Wrap(rw)
+		h.ServeHTTP(wrapped, req)
+
+		r := Record{
+			Time:      start.UTC(),
+			Method:    req.Method,
+			Path:      req.URL.Path,
+			Status:    sw.Status(),
+			LatencyMS: float64(now().Sub(start)) / float64(time.Millisecond),
+		}
+		r.User, _ = identity.UserFromContext(req.Context())
+		r.GUID, _ = tracker.GUIDFromContext(req.Context())
+		if body != nil {
+			r.BodySHA256, r.BodyPartial = body.sum()
+		}
+		if err := l.Append(r); err != nil {
+			log.Printf("audit: dropped record for %s %s: %v", req.Method, req.URL.Path, err)
+		}
+	})
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// maxDrain caps how much of the body sum reads past what the handler
+// consumed.
+const maxDrain = 1 << 20
+
+// hashingBody hashes the body as the handler reads it; sum hashes
+// whatever the handler left unread so the digest covers the whole body
+// when it can.
+type hashingBody struct {
+	io.ReadCloser
+	h      hash.Hash
+	eof    bool
+	closed bool
+}
+
+func (b *hashingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.h.Write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *hashingBody) Close() error {
+	b.closed = true
+	return b.ReadCloser.Close()
+}
+
+// sum returns the digest and whether it covers only part of the body.
+func (b *hashingBody) sum() (string, bool) {
+	partial := false
+	switch {
+	case b.eof:
+	case b.closed:
+		partial = true
+	default:
+		n, err := io.Copy(b.h, io.LimitReader(b.ReadCloser, maxDrain))
+		if err != nil {
+			partial = true
+		} else if n == maxDrain {
+			// Only partial if there really was more.
+			var one [1]byte
+			m, _ := b.ReadCloser.Read(one[:])
+			partial = m > 0
+		}
+	}
+	return hex.EncodeToString(b.h.Sum(nil)), partial
+}
@@ -0,0 +1,293 @@
+package audit
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+)
+
+func tempLog(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "audit.jsonl")
+}
+
+func TestMiddleware(t *testing.T) {
+	path := tempLog(t)
+	l, err := Open(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	calls := 0
+	now = func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls-1) * 5 * time.Millisecond)
+	}
+	defer func() { now = time.Now }()
+
+	h := l.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// Read only part of the body; the hash must still cover all of it.
+		req.Body.Read(make([]byte, 2))
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("hello"))
+	req = req.WithContext(identity.ContextWithUser(req.Context(), "fred"))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+	l.Close()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(lines))
+	}
+	want := `{"seq":1,"time":"2021-06-06T13:00:00Z","user":"fred","method":"POST","path":"/orders","status":201,` +
+		`"latency_ms":5,"body_sha256":"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824","prev":"","hash":"`
+	if !strings.HasPrefix(lines[0], want) {
+		t.Errorf("expected `%s...`, got `%s`", want, lines[0])
+	}
+	if strings.Contains(lines[1], "body_sha256") {
+		t.Errorf("expected no body hash for a GET, got `%s`", lines[1])
+	}
+}
+
+func TestPartialBody(t *testing.T) {
+	data := []struct {
+		name    string
+		body    string
+		handle  func(body io.ReadCloser)
+		partial bool
+	}{
+		{"unread", "hello", func(body io.ReadCloser) {}, false},
+		{"read_then_closed", "hello", func(body io.ReadCloser) { ioutil.ReadAll(body); body.Close() }, false},
+		{"closed_early", "hello", func(body io.ReadCloser) { body.Read(make([]byte, 2)); body.Close() }, true},
+		{"too_long", strings.Repeat("x", maxDrain+1), func(body io.ReadCloser) {}, true},
+		{"just_fits", strings.Repeat("x", maxDrain), func(body io.ReadCloser) {}, false},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			path := tempLog(t)
+			l, err := Open(Config{Path: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := l.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				d.handle(req.Body)
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(d.body)))
+			l.Close()
+			b, _ := ioutil.ReadFile(path)
+			if got := strings.Contains(string(b), `"body_partial":true`); got != d.partial {
+				t.Errorf("expected partial %v, got `%s`", d.partial, b)
+			}
+		})
+	}
+}
+
+func TestRotateAndReopen(t *testing.T) {
+	path := tempLog(t)
+	l, err := Open(Config{Path: path, MaxBytes: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := l.Append(Record{Method: "POST", Path: "/orders"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+	l, err = Open(Config{Path: path, MaxBytes: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append(Record{Method: "DELETE", Path: "/orders/1"})
+	l.Close()
+
+	files, _ := Files(path)
+	if len(files) < 3 {
+		t.Errorf("expected the log to have rotated, got %v", files)
+	}
+	if n, err := Verify(path); n != 6 || err != nil {
+		t.Errorf("expected 6 good records, got %d, %v", n, err)
+	}
+}
+
+func TestRotateAfterRetention(t *testing.T) {
+	path := tempLog(t)
+	l, err := Open(Config{Path: path, MaxBytes: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < 4; i++ {
+		l.Append(Record{Method: "POST", Path: "/orders"})
+	}
+	files, _ := Files(path)
+	if len(files) < 3 {
+		t.Fatalf("expected at least two rotated files, got %v", files)
+	}
+	countLines := func(f string) int {
+		b, _ := ioutil.ReadFile(f)
+		return strings.Count(string(b), "\n")
+	}
+	// Ship the oldest rotated file off the box, then rotate again.
+	shipped := countLines(files[0])
+	if err := os.Remove(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		l.Append(Record{Method: "POST", Path: "/orders"})
+	}
+	kept := 0
+	files, _ = Files(path)
+	for _, f := range files {
+		kept += countLines(f)
+	}
+	if kept != 7-shipped {
+		t.Errorf("expected %d records left, got %d in %v", 7-shipped, kept, files)
+	}
+	if n, err := Verify(path); n != int64(kept) || err != nil {
+		t.Errorf("expected the surviving %d records to verify, got %d, %v", kept, n, err)
+	}
+}
+
+func TestReopenAfterTornWrite(t *testing.T) {
+	data := []struct {
+		name string
+		tail string
+		good int64
+		seq  int64
+	}{
+		{"torn", `{"seq":3,"time":"2021-06`, 2, 3},
+		{"torn_only_line", `{"seq":1,"ti`, 0, 1},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			path := tempLog(t)
+			l, err := Open(Config{Path: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := int64(0); i < d.good; i++ {
+				l.Append(Record{Method: "POST", Path: "/orders"})
+			}
+			l.Close()
+			f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+			f.WriteString(d.tail)
+			f.Close()
+
+			l, err = Open(Config{Path: path})
+			if err != nil {
+				t.Fatalf("expected the log to open despite the torn line, got %v", err)
+			}
+			if err := l.Append(Record{Method: "DELETE", Path: "/orders/1"}); err != nil {
+				t.Fatal(err)
+			}
+			l.Close()
+			last, _, _, err := lastRecord(path)
+			if err != nil || last.Seq != d.seq || last.Method != "DELETE" {
+				t.Errorf("expected record %d to follow the torn line, got %+v, %v", d.seq, last, err)
+			}
+			n, err := Verify(path)
+			var broken *BreakError
+			if !errors.As(err, &broken) || broken.Line != int(d.good)+1 || !strings.Contains(broken.Reason, "malformed") || n != d.good {
+				t.Errorf("expected a break at the torn line after %d records, got %d, %v", d.good, n, err)
+			}
+		})
+	}
+}
+
+func TestReopenAfterMissingNewline(t *testing.T) {
+	path := tempLog(t)
+	l, _ := Open(Config{Path: path})
+	l.Append(Record{Method: "POST", Path: "/orders"})
+	l.Close()
+	b, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, b[:len(b)-1], 0600)
+
+	l, err := Open(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append(Record{Method: "POST", Path: "/orders"})
+	l.Close()
+	if n, err := Verify(path); n != 2 || err != nil {
+		t.Errorf("expected 2 good records, got %d, %v", n, err)
+	}
+}
+
+func TestVerifyTampering(t *testing.T) {
+	data := []struct {
+		name   string
+		tamper func(lines []string) []string
+		line   int
+		reason string
+	}{
+		{"edited", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `"user":"fred"`, `"user":"wilma"`, 1)
+			return lines
+		}, 2, "hash does not match"},
+		{"dropped", func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		}, 2, "expected sequence 2"},
+		{"truncated", func(lines []string) []string {
+			lines[2] = lines[2][:10]
+			return lines
+		}, 3, "malformed"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			path := tempLog(t)
+			l, err := Open(Config{Path: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 3; i++ {
+				l.Append(Record{User: "fred", Method: "POST", Path: "/orders"})
+			}
+			l.Close()
+			b, _ := ioutil.ReadFile(path)
+			lines := d.tamper(strings.Split(strings.TrimSpace(string(b)), "\n"))
+			ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+
+			_, err = Verify(path)
+			var broken *BreakError
+			if !errors.As(err, &broken) || broken.Line != d.line || !strings.Contains(broken.Reason, d.reason) {
+				t.Errorf("expected a break at line %d (%s), got %v", d.line, d.reason, err)
+			}
+		})
+	}
+}
+
+func TestMiddlewareKeepsFlusher(t *testing.T) {
+	l, err := Open(Config{Path: tempLog(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	var canFlush bool
+	h := l.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, canFlush = rw.(http.Flusher)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events", nil))
+	if !canFlush {
+		t.Error("expected the handler to get a Flusher")
+	}
+}
@@ -0,0 +1,331 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one audited request. Each record's Hash covers its own
+// fields and the previous record's Hash, so editing, dropping or
+// reordering lines breaks the chain from that point on.
+type Record struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	GUID      string    `json:"guid,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	LatencyMS float64   `json:"latency_ms"`
+	// BodySHA256 is the hex digest of the request body, for mutating
+	// methods only.
+	BodySHA256 string `json:"body_sha256,omitempty"`
+	// BodyPartial marks a BodySHA256 that covers only the start of the
+	// body, because the handler closed it early or the rest was too
+	// long to drain.
+	BodyPartial bool   `json:"body_partial,omitempty"`
+	Prev        string `json:"prev"`
+	Hash        string `json:"hash,omitempty"`
+}
+
+// computeHash returns the chain hash of r, ignoring r.Hash.
+func computeHash(r Record) (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Config says where a Log writes.
+type Config struct {
+	Path string
+	// MaxBytes is the size at which the file is rotated to Path.1,
+	// Path.2 and so on. Zero means 100 MB. Rotated files are never
+	// deleted; retention is left to whoever ships them off the box.
+	MaxBytes int64
+}
+
+// Log appends hash-chained records to a JSON-lines file. The chain
+// carries across rotations and restarts.
+type Log struct {
+	cfg  Config
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	seq  int64
+	last string
+}
+
+// Open opens cfg.Path for appending, picking the chain up from the
+// last record already written. A last line torn by a crash mid-append is
+// ended with a newline and left in place, so Verify reports the gap, and
+// the chain carries on from the record before it.
+func Open(cfg Config) (*Log, error) {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 100 << 20
+	}
+	l := &Log{cfg: cfg}
+	files, err := Files(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	unterminated := false
+	for i := len(files) - 1; i >= 0; i-- {
+		last, ok, open, err := lastRecord(files[i])
+		if err != nil {
+			return nil, err
+		}
+		if files[i] == cfg.Path {
+			unterminated = open
+		}
+		if ok {
+			l.seq, l.last = last.Seq, last.Hash
+			break
+		}
+	}
+	l.f, err = os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if unterminated {
+		if _, err := l.f.Write([]byte("\n")); err != nil {
+			l.f.Close()
+			return nil, err
+		}
+	}
+	info, err := l.f.Stat()
+	if err != nil {
+		l.f.Close()
+		return nil, err
+	}
+	l.size = info.Size()
+	return l, nil
+}
+
+// Append fills in r's Seq, Prev and Hash and writes it out.
+func (l *Log) Append(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r.Seq = l.seq + 1
+	r.Prev = l.last
+	hash, err := computeHash(r)
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if l.size > 0 && l.size+int64(len(line)) > l.cfg.MaxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	l.seq, l.last = r.Seq, r.Hash
+	return nil
+}
+
+func (l *Log) rotate() error {
+	files, err := Files(l.cfg.Path)
+	if err != nil {
+		return err
+	}
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	// Suffixes go on counting up after old files are shipped off, so a
+	// gap never leads to an existing file being overwritten.
+	next := 1
+	for _, f := range files {
+		if n, err := strconv.Atoi(strings.TrimPrefix(f, l.cfg.Path+".")); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	if err := os.Rename(l.cfg.Path, fmt.Sprintf("%s.%d", l.cfg.Path, next)); err != nil {
+		return err
+	}
+	l.f, err = os.OpenFile(l.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	l.size = 0
+	return err
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// Files returns the rotated files for path, oldest first, followed by
+// path itself. Missing files are left out.
+func Files(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	type rotated struct {
+		n    int
+		path string
+	}
+	var rs []rotated
+	for _, m := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(m, path+"."))
+		if err == nil && n > 0 {
+			rs = append(rs, rotated{n, m})
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].n < rs[j].n })
+	var out []string
+	for _, r := range rs {
+		out = append(out, r.path)
+	}
+	if _, err := os.Stat(path); err == nil {
+		out = append(out, path)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return out, nil
+}
+
+// maxRecord bounds how far back from the end of a file lastRecord looks,
+// and how long a line Verify accepts.
+const maxRecord = 1 << 20
+
+// lastRecord returns the last complete record in path, if any. open
+// reports a last line without its newline. If that line doesn't parse
+// either, the write was torn and the record before it is returned.
+func lastRecord(path string) (r Record, ok, open bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Record{}, false, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Record{}, false, false, err
+	}
+	offset := info.Size() - maxRecord
+	if offset < 0 {
+		offset = 0
+	}
+	b, err := ioutil.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return Record{}, false, false, err
+	}
+	open = len(b) > 0 && b[len(b)-1] != '\n'
+	b = bytes.TrimRight(b, "\n")
+	if len(b) == 0 {
+		return Record{}, false, open, nil
+	}
+	i := bytes.LastIndexByte(b, '\n')
+	err = json.Unmarshal(b[i+1:], &r)
+	if err != nil && open {
+		if i < 0 && offset == 0 {
+			return Record{}, false, open, nil
+		}
+		if i >= 0 {
+			b = b[:i]
+			err = json.Unmarshal(b[bytes.LastIndexByte(b, '\n')+1:], &r)
+		}
+	}
+	if err != nil {
+		return Record{}, false, false, fmt.Errorf("reading last record of %s: %w", path, err)
+	}
+	return r, true, open, nil
+}
+
+// BreakError reports the first record that does not chain onto the one
+// before it.
+type BreakError struct {
+	File   string
+	Line   int
+	Seq    int64
+	Reason string
+}
+
+func (e *BreakError) Error() string {
+	return fmt.Sprintf("%s:%d: record %d: %s", e.File, e.Line, e.Seq, e.Reason)
+}
+
+// Verify walks every file of the log at path in order and checks each
+// link of the chain. It returns the number of records checked and a
+// *BreakError at the first broken link.
+//
+// The chain is picked up from the first record still on disk, so a log
+// whose oldest files have been shipped off verifies cleanly. Records
+// dropped from the very start therefore go unnoticed; compare the first
+// Seq with what was shipped to catch that.
+func Verify(path string) (int64, error) {
+	files, err := Files(path)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	var prev string
+	var seq int64
+	anchored := false
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return count, err
+		}
+		s := bufio.NewScanner(f)
+		s.Buffer(nil, maxRecord)
+		line := 0
+		for s.Scan() {
+			line++
+			var r Record
+			if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+				f.Close()
+				return count, &BreakError{file, line, seq + 1, "malformed: " + err.Error()}
+			}
+			fail := func(reason string) error {
+				f.Close()
+				return &BreakError{file, line, r.Seq, reason}
+			}
+			if !anchored {
+				seq, prev, anchored = r.Seq-1, r.Prev, true
+			}
+			switch want, err := computeHash(r); {
+			case err != nil:
+				return count, fail(err.Error())
+			case r.Seq != seq+1:
+				return count, fail(fmt.Sprintf("expected sequence %d", seq+1))
+			case r.Prev != prev:
+				return count, fail("prev does not match the previous record's hash")
+			case r.Hash != want:
+				return count, fail("hash does not match the record's contents")
+			}
+			prev, seq = r.Hash, r.Seq
+			count++
+		}
+		err = s.Err()
+		f.Close()
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
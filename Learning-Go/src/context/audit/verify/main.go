@@ -0,0 +1,40 @@
+// Command verify checks the hash chain of an audit log, including its
+// rotated files, and reports the first broken link.
+//
+//	go run ./audit/verify /var/log/orders/audit.jsonl
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/audit"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: verify <audit log path>")
+		os.Exit(2)
+	}
+	files, err := audit.Files(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "no audit log at %s\n", os.Args[1])
+		os.Exit(2)
+	}
+	n, err := audit.Verify(os.Args[1])
+	var broken *audit.BreakError
+	switch {
+	case errors.As(err, &broken):
+		fmt.Printf("chain broken after %d good records: %v\n", n, broken)
+		os.Exit(1)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Printf("ok: %d records in %d files\n", n, len(files))
+}
@@ -0,0 +1,129 @@
+package authz
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/logging"
+)
+
+// Authorizer answers permission checks from a policy file and reloads it
+// when the file changes.
+type Authorizer struct {
+	// Logger receives an audit entry for every denied request. Nil means
+	// the standard log package.
+	Logger *logging.Logger
+
+	path string
+
+	mu      sync.RWMutex
+	policy  *compiled
+	modTime time.Time
+}
+
+func Load(path string) (*Authorizer, error) {
+	a := &Authorizer{path: path}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload rereads the policy file. A policy that fails to parse or compile
+// leaves the current one in place.
+func (a *Authorizer) Reload() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return err
+	}
+	p, err := LoadPolicy(a.path)
+	if err != nil {
+		return err
+	}
+	c, err := compile(p)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.policy = c
+	a.modTime = info.ModTime()
+	return nil
+}
+
+// Watch polls the policy file every interval and reloads it when its
+// modification time changes, until ctx is done. Reload errors go to
+// onError, if set.
+func (a *Authorizer) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		info, err := os.Stat(a.path)
+		if err == nil {
+			a.mu.RLock()
+			changed := !info.ModTime().Equal(a.modTime)
+			a.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			err = a.Reload()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (a *Authorizer) Allowed(user, permission string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.policy.allowed(user, permission)
+}
+
+// Require lets a request through only if the user in its context has
+// permission. It must run after identity.Middleware; requests with no
+// user get a 401, users without the permission a 403.
+func (a *Authorizer) Require(permission string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			user, ok := identity.UserFromContext(req.Context())
+			if !ok {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if !a.Allowed(user, permission) {
+				a.audit(req, user, permission)
+				rw.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(rw, req)
+		})
+	}
+}
+
+func (a *Authorizer) audit(req *http.Request, user, permission string) {
+	if a.Logger == nil {
+		log.Printf("authz: denied %s %s to %q: missing %q", req.Method, req.URL.Path, user, permission)
+		return
+	}
+	a.Logger.Warn(req.Context(), "access denied",
+		"permission", permission, "method", req.Method, "path", req.URL.Path)
+}
+
+// Roles returns the roles the policy assigns to user, falling back to
+// the default roles.
+func (a *Authorizer) Roles(user string) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.policy.roles(user)
+}
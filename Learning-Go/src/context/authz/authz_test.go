@@ -0,0 +1,162 @@
+package authz
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/logging"
+)
+
+const testPolicy = `
+roles:
+  viewer:
+    permissions: ["orders:read", "reports:*:read"]
+  clerk:
+    inherits: [viewer]
+    permissions: ["orders:write"]
+  manager:
+    inherits: [clerk]
+    permissions: ["orders:*"]
+  admin:
+    permissions: ["*"]
+users:
+  fred: [clerk]
+  wilma: [manager]
+  barney: [admin]
+default_roles: [viewer]
+`
+
+func writePolicy(t *testing.T, name, body string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "authz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAllowed(t *testing.T) {
+	a, err := Load(writePolicy(t, "policy.yaml", testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []struct {
+		user string
+		perm string
+		want bool
+	}{
+		{"fred", "orders:read", true},
+		{"fred", "orders:write", true},
+		{"fred", "orders:delete", false},
+		{"fred", "reports:sales:read", true},
+		{"fred", "reports:sales:write", false},
+		{"wilma", "orders:delete", true},
+		{"wilma", "orders:items:delete", true},
+		{"wilma", "users:read", false},
+		{"barney", "users:delete", true},
+		{"stranger", "orders:read", true},
+		{"stranger", "orders:write", false},
+	}
+	for _, d := range data {
+		t.Run(d.user+"_"+d.perm, func(t *testing.T) {
+			if got := a.Allowed(d.user, d.perm); got != d.want {
+				t.Errorf("expected %v, got %v", d.want, got)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	data := []struct {
+		name   string
+		policy string
+		errMsg string
+	}{
+		{"cycle", `{"roles": {"a": {"inherits": ["b"]}, "b": {"inherits": ["a"]}}}`, "role inheritance cycle"},
+		{"unknown_parent", `{"roles": {"a": {"inherits": ["ghost"]}}}`, `unknown role "ghost"`},
+		{"unknown_user_role", `{"roles": {}, "users": {"fred": ["ghost"]}}`, `user "fred" has unknown role "ghost"`},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			_, err := Load(writePolicy(t, "policy.json", d.policy))
+			if err == nil || !strings.Contains(err.Error(), d.errMsg) {
+				t.Errorf("expected error containing `%s`, got %v", d.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	a, err := Load(writePolicy(t, "policy.yaml", testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	a.Logger = logging.New(&buf, logging.Config{Format: logging.Logfmt})
+	h := a.Require("orders:delete")(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	data := []struct {
+		user string
+		code int
+	}{
+		{"", http.StatusUnauthorized},
+		{"fred", http.StatusForbidden},
+		{"wilma", http.StatusOK},
+	}
+	for _, d := range data {
+		req := httptest.NewRequest(http.MethodDelete, "/orders/1", nil)
+		if d.user != "" {
+			req = req.WithContext(identity.ContextWithUser(req.Context(), d.user))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != d.code {
+			t.Errorf("%q: expected %d, got %d", d.user, d.code, rec.Code)
+		}
+	}
+	if !strings.Contains(buf.String(), `msg="access denied" user=fred permission=orders:delete method=DELETE path=/orders/1`) {
+		t.Errorf("expected an audit entry for fred, got %s", buf.String())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := writePolicy(t, "policy.json", `{"roles": {"r": {"permissions": ["a"]}}, "users": {"fred": ["r"]}}`)
+	a, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Watch(ctx, 5*time.Millisecond, nil)
+
+	err = ioutil.WriteFile(path, []byte(`{"roles": {"r": {"permissions": ["b"]}}, "users": {"fred": ["r"]}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	deadline := time.Now().Add(2 * time.Second)
+	for !a.Allowed("fred", "b") {
+		if time.Now().After(deadline) {
+			t.Fatal("policy was not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if a.Allowed("fred", "a") {
+		t.Error("expected the old permission to be gone")
+	}
+}
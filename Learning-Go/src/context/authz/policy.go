@@ -0,0 +1,155 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the on-disk form of the role mapping, for example:
+//
+//	roles:
+//	  viewer: {permissions: ["orders:read"]}
+//	  clerk:  {inherits: [viewer], permissions: ["orders:write"]}
+//	  admin:  {permissions: ["*"]}
+//	users:
+//	  fred: [clerk]
+//	default_roles: [viewer]
+//
+// Permissions are colon-separated. A "*" segment matches any single
+// segment, and a trailing "*" matches everything below it.
+type Policy struct {
+	Roles        map[string]Role     `json:"roles" yaml:"roles"`
+	Users        map[string][]string `json:"users" yaml:"users"`
+	DefaultRoles []string            `json:"default_roles" yaml:"default_roles"`
+}
+
+type Role struct {
+	Inherits    []string `json:"inherits" yaml:"inherits"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+// ParsePolicy reads a policy in YAML when path ends in .yaml or .yml and
+// in JSON otherwise.
+func ParsePolicy(path string, data []byte) (Policy, error) {
+	var p Policy
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &p)
+	default:
+		err = json.Unmarshal(data, &p)
+	}
+	if err != nil {
+		return Policy{}, fmt.Errorf("authz: parsing %s: %w", path, err)
+	}
+	return p, nil
+}
+
+func LoadPolicy(path string) (Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	return ParsePolicy(path, data)
+}
+
+// compiled has every role's inherited permissions flattened in.
+type compiled struct {
+	perms        map[string][]string
+	users        map[string][]string
+	defaultRoles []string
+}
+
+func compile(p Policy) (*compiled, error) {
+	c := &compiled{perms: map[string][]string{}, users: p.Users, defaultRoles: p.DefaultRoles}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("authz: role inheritance cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		role, ok := p.Roles[name]
+		if !ok {
+			return fmt.Errorf("authz: unknown role %q", name)
+		}
+		state[name] = visiting
+		perms := append([]string(nil), role.Permissions...)
+		for _, parent := range role.Inherits {
+			if err := visit(parent, append(path, name)); err != nil {
+				return err
+			}
+			perms = append(perms, c.perms[parent]...)
+		}
+		for _, perm := range role.Permissions {
+			if perm == "" || strings.Contains(perm, "**") {
+				return fmt.Errorf("authz: role %q has invalid permission %q", name, perm)
+			}
+		}
+		c.perms[name] = perms
+		state[name] = done
+		return nil
+	}
+	for name := range p.Roles {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	for user, roles := range p.Users {
+		for _, r := range roles {
+			if _, ok := p.Roles[r]; !ok {
+				return nil, fmt.Errorf("authz: user %q has unknown role %q", user, r)
+			}
+		}
+	}
+	for _, r := range p.DefaultRoles {
+		if _, ok := p.Roles[r]; !ok {
+			return nil, fmt.Errorf("authz: unknown default role %q", r)
+		}
+	}
+	return c, nil
+}
+
+func (c *compiled) roles(user string) []string {
+	if roles, ok := c.users[user]; ok {
+		return roles
+	}
+	return c.defaultRoles
+}
+
+func (c *compiled) allowed(user, perm string) bool {
+	for _, r := range c.roles(user) {
+		for _, granted := range c.perms[r] {
+			if match(granted, perm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// match reports whether the granted pattern covers perm.
+func match(granted, perm string) bool {
+	gs := strings.Split(granted, ":")
+	ps := strings.Split(perm, ":")
+	for i, g := range gs {
+		if g == "*" && i == len(gs)-1 {
+			return true
+		}
+		if i >= len(ps) || (g != "*" && g != ps[i]) {
+			return false
+		}
+	}
+	return len(gs) == len(ps)
+}
@@ -6,32 +6,22 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"sync"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/fanout"
 )
 
 var client = http.Client{}
 
 func callBoth(ctx context.Context, errVal string, slowURL string,
 	fastURL string) {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		err := callServer(ctx, "slow", slowURL)
-		if err != nil {
-			cancel()
-		}
-	}()
-	go func() {
-		defer wg.Done()
-		err := callServer(ctx, "fast", fastURL+"?error="+errVal)
-		if err != nil {
-			cancel()
-		}
-	}()
-	wg.Wait()
+	g, ctx := fanout.New(ctx, fanout.Config{})
+	g.Go(func(ctx context.Context) error {
+		return callServer(ctx, "slow", slowURL)
+	})
+	g.Go(func(ctx context.Context) error {
+		return callServer(ctx, "fast", fastURL+"?error="+errVal)
+	})
+	g.Wait()
 	fmt.Println("done with both")
 }
 
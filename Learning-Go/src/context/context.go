@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/ctxdebug"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/remote"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/tracker"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/transport"
 )
 
 //metadata that is required to correctly process the request, and metadata on when to stop processing the request.
@@ -85,42 +90,6 @@ type ServiceCaller struct {
 //	}
 //}
 
-type userKey int
-const key userKey = 1
-
-func ContextWithUser(ctx context.Context, user string) context.Context {
-	return context.WithValue(ctx, key, user)
-}
-
-func UserFromContext(ctx context.Context) (string, bool) {
-	user, ok := ctx.Value(key).(string)
-	return user, ok
-}
-
-// a real implementation would be signed to make sure
-// the user didn't spoof their identity
-func extractUser(req *http.Request) (string, error) {
-	userCookie, err := req.Cookie("user")
-	if err != nil {
-		return "", err
-	}
-	return userCookie.Value, nil
-}
-
-func Middleware(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		user, err := extractUser(req)
-		if err != nil {
-			rw.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-		ctx := req.Context()
-		ctx = ContextWithUser(ctx, user)
-		req = req.WithContext(ctx)
-		h.ServeHTTP(rw, req)
-	})
-}
-
 //func (c Controller) handleRequest(rw http.ResponseWriter, req *http.Request) {
 //	ctx := req.Context()
 //	user, ok := identity.UserFromContext(ctx)
@@ -146,23 +115,32 @@ type RequestDecorator func(*http.Request) *http.Request
 
 type BusinessLogic struct {
 	RequestDecorator RequestDecorator
-	Logger                     Logger
-	Remote                     string
+	// Client sends the remote request, usually built with
+	// transport.Chain. Nil means http.DefaultClient.
+	Client *http.Client
+	Logger Logger
+	Remote string
+}
+
+type queryResult struct {
+	Result string `json:"result"`
 }
 
 func (bl BusinessLogic) businessLogic(
 	ctx context.Context, user string, data string) (string, error) {
-	bl.Logger.Log(ctx, "starting businessLogic for " + user + " with "+ data)
-	req, err := http.NewRequestWithContext(ctx,
-		http.MethodGet, bl.Remote+"?query="+data, nil)
-	if err != nil {
-		bl.Logger.Log(ctx, "error building remote request:" + err)
+	bl.Logger.Log(ctx, "starting businessLogic for "+user+" with "+data)
+	rc := remote.Client{
+		BaseURL:  bl.Remote,
+		HTTP:     bl.Client,
+		Decorate: bl.RequestDecorator,
+		Logger:   bl.Logger,
+	}
+	var qr queryResult
+	if err := rc.Query(ctx, data, &qr); err != nil {
+		bl.Logger.Log(ctx, "remote query failed: "+err.Error())
 		return "", err
 	}
-	req = bl.RequestDecorator(req)
-	resp, err := http.DefaultClient.Do(req)
-	// processing continues
-	fmt.Println(resp)
+	return qr.Result, nil
 }
 
 func main() {
@@ -185,10 +163,16 @@ func main() {
 	<-child.Done()
 	end := time.Now()
 	fmt.Println(end.Sub(start))
+	fmt.Println(ctxdebug.Inspect(child))
 
 	bl := BusinessLogic{
-		RequestDecorator: tracker.Request,
-		Logger:           tracker.Logger{},
-		Remote:           "http://www.example.com/query",
+		Client: transport.NewChain(
+			transport.Trace(tracker.DefaultTracer),
+			transport.Retry(transport.RetryConfig{}),
+		).Client(nil),
+		Logger: tracker.Logger{},
+		Remote: "http://www.example.com/query",
 	}
+	result, err := bl.businessLogic(ctx, "fred", "hello world")
+	fmt.Println(result, err)
 }
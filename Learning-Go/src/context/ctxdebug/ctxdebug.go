@@ -0,0 +1,127 @@
+package ctxdebug
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key is a named context key. Creating one registers it, so Inspect can
+// list its value in any context without knowing about the package that
+// owns it.
+type Key struct {
+	name string
+	typ  reflect.Type
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]*Key{}
+)
+
+// NewKey registers a key for values of the same type as zero. Names must
+// be unique; by convention they are prefixed with the owning package, as
+// in "identity.user". NewKey is meant for package-level vars and panics
+// on a duplicate name.
+func NewKey(name string, zero interface{}) *Key {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("ctxdebug: duplicate key " + name)
+	}
+	k := &Key{name: name, typ: reflect.TypeOf(zero)}
+	registry[name] = k
+	return k
+}
+
+func (k *Key) Name() string   { return k.name }
+func (k *Key) String() string { return k.name }
+
+// WithValue returns a copy of ctx with v stored under k. It panics if v
+// is not of the key's type, the same way context.WithValue panics on a
+// nil key.
+func (k *Key) WithValue(ctx context.Context, v interface{}) context.Context {
+	if k.typ != nil && reflect.TypeOf(v) != k.typ {
+		panic(fmt.Sprintf("ctxdebug: key %s holds %s, got %T", k.name, k.typ, v))
+	}
+	return context.WithValue(ctx, k, v)
+}
+
+func (k *Key) Value(ctx context.Context) interface{} {
+	return ctx.Value(k)
+}
+
+// Keys returns every registered key, sorted by name.
+func Keys() []*Key {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]*Key, 0, len(registry))
+	for _, k := range registry {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+type Value struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// Snapshot describes a context at one moment.
+type Snapshot struct {
+	Values   []Value    `json:"values"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Remaining is the time left until Deadline when the snapshot was
+	// taken.
+	Remaining time.Duration `json:"remaining_ns,omitempty"`
+	Err       string        `json:"err,omitempty"`
+	// Cause is the cancellation cause, when it says more than Err.
+	Cause string `json:"cause,omitempty"`
+}
+
+// Inspect lists every registered value present in ctx along with its
+// deadline and cancellation state.
+func Inspect(ctx context.Context) Snapshot {
+	var s Snapshot
+	for _, k := range Keys() {
+		if v := ctx.Value(k); v != nil {
+			s.Values = append(s.Values, Value{Key: k.name, Type: fmt.Sprintf("%T", v), Value: v})
+		}
+	}
+	if d, ok := ctx.Deadline(); ok {
+		s.Deadline = &d
+		s.Remaining = time.Until(d)
+	}
+	if err := ctx.Err(); err != nil {
+		s.Err = err.Error()
+		if cause := context.Cause(ctx); cause != nil && cause != err {
+			s.Cause = cause.Error()
+		}
+	}
+	return s
+}
+
+// String renders s on one line, as used for the debug response header.
+func (s Snapshot) String() string {
+	var parts []string
+	for _, v := range s.Values {
+		parts = append(parts, fmt.Sprintf("%s=%v", v.Key, v.Value))
+	}
+	if s.Deadline != nil {
+		parts = append(parts, "deadline="+s.Deadline.UTC().Format(time.RFC3339Nano),
+			"remaining="+s.Remaining.Round(time.Millisecond).String())
+	}
+	if s.Err != "" {
+		parts = append(parts, "err="+s.Err)
+	}
+	if s.Cause != "" {
+		parts = append(parts, "cause="+s.Cause)
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,120 @@
+package ctxdebug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testUser  = NewKey("ctxdebug_test.user", "")
+	testCount = NewKey("ctxdebug_test.count", 0)
+)
+
+func TestInspect(t *testing.T) {
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	ctx = testCount.WithValue(testUser.WithValue(ctx, "fred"), 3)
+
+	s := Inspect(ctx)
+	if len(s.Values) != 2 || s.Values[0].Key != "ctxdebug_test.count" || s.Values[1].Value != "fred" {
+		t.Errorf("unexpected values %+v", s.Values)
+	}
+	if s.Deadline == nil || !s.Deadline.Equal(deadline) || s.Err != "" {
+		t.Errorf("unexpected deadline state %+v", s)
+	}
+	if got := s.String(); !strings.HasPrefix(got, "ctxdebug_test.count=3; ctxdebug_test.user=fred; deadline=") {
+		t.Errorf("unexpected string `%s`", got)
+	}
+}
+
+func TestInspectCause(t *testing.T) {
+	data := []struct {
+		name  string
+		ctx   func() context.Context
+		err   string
+		cause string
+	}{
+		{"cancelled", func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx
+		}, "context canceled", ""},
+		{"with_cause", func() context.Context {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			cancel(errors.New("client went away"))
+			return ctx
+		}, "context canceled", "client went away"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			s := Inspect(d.ctx())
+			if s.Err != d.err || s.Cause != d.cause {
+				t.Errorf("expected `%s`/`%s`, got `%s`/`%s`", d.err, d.cause, s.Err, s.Cause)
+			}
+		})
+	}
+}
+
+func TestWrongType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a value of the wrong type")
+		}
+	}()
+	testCount.WithValue(context.Background(), "three")
+}
+
+func TestMiddleware(t *testing.T) {
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("ok"))
+	})
+	withUser := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			h.ServeHTTP(rw, req.WithContext(testUser.WithValue(req.Context(), "fred")))
+		})
+	}
+	data := []struct {
+		name   string
+		dev    bool
+		ask    bool
+		header string
+	}{
+		{"dev", true, true, "ctxdebug_test.user=fred"},
+		{"not_asked", true, false, ""},
+		{"prod", false, true, ""},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if d.ask {
+				req.Header.Set(Header, "1")
+			}
+			rec := httptest.NewRecorder()
+			withUser(Middleware(d.dev)(h)).ServeHTTP(rec, req)
+			if got := rec.Header().Get(Header); got != d.header {
+				t.Errorf("expected `%s`, got `%s`", d.header, got)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/debug/context", nil)
+	req = req.WithContext(testUser.WithValue(req.Context(), "fred"))
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, req)
+	var s Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Values) != 1 || s.Values[0].Value != "fred" {
+		t.Errorf("unexpected snapshot %+v", s)
+	}
+}
@@ -0,0 +1,61 @@
+package ctxdebug
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Header is both the request header that asks for a context dump and
+// the response header that carries it.
+const Header = "X-Debug-Context"
+
+// Middleware adds a Header response header describing the request
+// context to responses whose request set Header. It does nothing unless
+// dev is true, since the dump can include user identities. Put it inside
+// the middleware whose values should show up.
+func Middleware(dev bool) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		if !dev {
+			return h
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.Header.Get(Header) == "" {
+				h.ServeHTTP(rw, req)
+				return
+			}
+			h.ServeHTTP(&headerWriter{ResponseWriter: rw, req: req}, req)
+		})
+	}
+}
+
+type headerWriter struct {
+	http.ResponseWriter
+	req   *http.Request
+	wrote bool
+}
+
+func (hw *headerWriter) WriteHeader(code int) {
+	if !hw.wrote {
+		hw.wrote = true
+		hw.Header().Set(Header, Inspect(hw.req.Context()).String())
+	}
+	hw.ResponseWriter.WriteHeader(code)
+}
+
+func (hw *headerWriter) Write(b []byte) (int, error) {
+	if !hw.wrote {
+		hw.WriteHeader(http.StatusOK)
+	}
+	return hw.ResponseWriter.Write(b)
+}
+
+// Handler serves the request's own context as JSON. Mount it behind the
+// same middleware as the real handlers, and only in dev mode.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(rw)
+		enc.SetIndent("", "  ")
+		enc.Encode(Inspect(req.Context()))
+	})
+}
@@ -0,0 +1,77 @@
+package deadline
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header carries the caller's remaining time budget in milliseconds. A
+// duration rather than a timestamp keeps clock skew between hosts out of
+// the picture.
+const Header = "X-Request-Timeout-Ms"
+
+var now = time.Now
+
+// Request writes the time left on req's context deadline into Header. It
+// is a RequestDecorator; requests without a deadline are left alone.
+func Request(req *http.Request) *http.Request {
+	d, ok := req.Context().Deadline()
+	if !ok {
+		return req
+	}
+	remaining := d.Sub(now()).Milliseconds()
+	if remaining < 0 {
+		remaining = 0
+	}
+	req.Header.Set(Header, strconv.FormatInt(remaining, 10))
+	return req
+}
+
+type Config struct {
+	// Margin is subtracted from the caller's budget to leave it time to
+	// receive and handle our response.
+	Margin time.Duration
+	// Max caps the budget a caller can grant. Zero means no cap.
+	Max time.Duration
+}
+
+// Middleware applies the budget from Header to the request context.
+// Requests whose budget is already spent, after Margin, get a 504 without
+// reaching h. Requests without the header are passed through unchanged.
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			v := req.Header.Get(Header)
+			if v == "" {
+				h.ServeHTTP(rw, req)
+				return
+			}
+			ms, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				h.ServeHTTP(rw, req)
+				return
+			}
+			// Clamp before converting, so a huge header can't overflow
+			// into a budget that is already spent.
+			if max := int64(math.MaxInt64 / time.Millisecond); ms > max {
+				ms = max
+			} else if ms < 0 {
+				ms = 0
+			}
+			budget := time.Duration(ms)*time.Millisecond - cfg.Margin
+			if cfg.Max > 0 && budget > cfg.Max {
+				budget = cfg.Max
+			}
+			if budget <= 0 {
+				rw.WriteHeader(http.StatusGatewayTimeout)
+				return
+			}
+			ctx, cancel := context.WithDeadline(req.Context(), now().Add(budget))
+			defer cancel()
+			h.ServeHTTP(rw, req.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequest(t *testing.T) {
+	start := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(1500*time.Millisecond))
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	if got := Request(req).Header.Get(Header); got != "1500" {
+		t.Errorf("expected 1500, got `%s`", got)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if got := Request(req).Header.Get(Header); got != "" {
+		t.Errorf("expected no header without a deadline, got `%s`", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	start := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	data := []struct {
+		name     string
+		header   string
+		code     int
+		deadline time.Duration
+	}{
+		{"no_header", "", http.StatusOK, 0},
+		{"margin_applied", "1000", http.StatusOK, 900 * time.Millisecond},
+		{"capped", "60000", http.StatusOK, 5 * time.Second},
+		{"expired", "0", http.StatusGatewayTimeout, 0},
+		{"eaten_by_margin", "80", http.StatusGatewayTimeout, 0},
+		{"huge", "9223372036854775807", http.StatusOK, 5 * time.Second},
+		{"huge_negative", "-9223372036854775807", http.StatusGatewayTimeout, 0},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			called := false
+			var got time.Duration
+			h := Middleware(Config{Margin: 100 * time.Millisecond, Max: 5 * time.Second})(
+				http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					called = true
+					if dl, ok := req.Context().Deadline(); ok {
+						got = dl.Sub(start)
+					}
+				}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if d.header != "" {
+				req.Header.Set(Header, d.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != d.code {
+				t.Errorf("expected status %d, got %d", d.code, rec.Code)
+			}
+			if called != (d.code == http.StatusOK) {
+				t.Errorf("handler called = %v for status %d", called, rec.Code)
+			}
+			if got != d.deadline {
+				t.Errorf("expected deadline in %v, got %v", d.deadline, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package fanout
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+type Config struct {
+	// Limit caps how many tasks run at once. Zero means no limit.
+	Limit int
+	// AllErrors makes Wait return every task error as Errors instead of
+	// only the first one.
+	AllErrors bool
+}
+
+// Group runs context-aware tasks concurrently. The first task to fail
+// cancels the context shared by all of them.
+type Group struct {
+	cfg    Config
+	cancel context.CancelFunc
+	ctx    context.Context
+	wg     sync.WaitGroup
+	sem    chan struct{}
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// New returns a Group and the context its tasks receive. The context is
+// cancelled on the first error or once Wait returns.
+func New(ctx context.Context, cfg Config) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Group{cfg: cfg, ctx: ctx, cancel: cancel}
+	if cfg.Limit > 0 {
+		g.sem = make(chan struct{}, cfg.Limit)
+	}
+	return g, ctx
+}
+
+// Go starts task in its own goroutine. With a Limit set, Go blocks until
+// a slot is free.
+func (g *Group) Go(task func(ctx context.Context) error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+		if err := g.run(task); err != nil {
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+			g.cancel()
+		}
+	}()
+}
+
+func (g *Group) run(task func(ctx context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return task(g.ctx)
+}
+
+// Wait blocks until every task has returned, then returns the first
+// error, or all of them if AllErrors is set.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errs) == 0 {
+		return nil
+	}
+	if g.cfg.AllErrors {
+		return append(Errors(nil), g.errs...)
+	}
+	return g.errs[0]
+}
+
+// PanicError is returned in place of a task that panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v\n%s", pe.Value, pe.Stack)
+}
+
+// Errors is every error returned by a Group's tasks, in the order they
+// failed.
+type Errors []error
+
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("%d tasks failed: %s", len(es), strings.Join(msgs, "; "))
+}
+
+func (es Errors) Unwrap() []error {
+	return es
+}
@@ -0,0 +1,92 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFirstErrorCancels(t *testing.T) {
+	boom := errors.New("boom")
+	g, ctx := New(context.Background(), Config{})
+	g.Go(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return errors.New("not cancelled")
+		}
+	})
+	g.Go(func(ctx context.Context) error {
+		return boom
+	})
+	if err := g.Wait(); err != boom {
+		t.Errorf("expected `%v`, got `%v`", boom, err)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the shared context to be cancelled")
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	g, _ := New(context.Background(), Config{AllErrors: true})
+	g.Go(func(ctx context.Context) error { return errors.New("first") })
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	g.Go(func(ctx context.Context) error { return nil })
+	err := g.Wait()
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected 2 aggregated errors, got %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected aggregated errors to include context.Canceled, got %v", err)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	var running, peak int32
+	g, _ := New(context.Background(), Config{Limit: 2})
+	for i := 0; i < 10; i++ {
+		g.Go(func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if peak > 2 {
+		t.Errorf("expected at most 2 concurrent tasks, saw %d", peak)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	g, _ := New(context.Background(), Config{})
+	g.Go(func(ctx context.Context) error {
+		var m map[string]int
+		m["x"] = 1
+		return nil
+	})
+	err := g.Wait()
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected a PanicError, got %v", err)
+	}
+	if !strings.Contains(string(pe.Stack), "fanout_test.go") {
+		t.Errorf("expected stack to point at the panicking task, got:\n%s", pe.Stack)
+	}
+}
@@ -0,0 +1,240 @@
+package faultserver
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Latency delays every response; nil means no delay.
+	Latency Latency
+	// ErrorRate is the fraction of requests answered with one of
+	// ErrorCodes (500 if empty) instead of the normal response.
+	ErrorRate  float64
+	ErrorCodes []int
+	// ResetRate is the fraction of requests whose connection is reset
+	// without any response.
+	ResetRate float64
+	// DripChunk and DripInterval, when both set, send response bodies
+	// DripChunk bytes at a time with a pause in between.
+	DripChunk    int
+	DripInterval time.Duration
+	// Handler answers requests that aren't faulted or scripted. Nil means
+	// a 200 with the body "ok".
+	Handler http.Handler
+	// Seed makes the random faults repeatable.
+	Seed int64
+}
+
+// Step is one scripted response.
+type Step struct {
+	Status  int
+	Header  http.Header
+	Body    string
+	Latency time.Duration
+	Reset   bool
+}
+
+// Request is what the server saw of one request. Cancelled is set when the
+// client went away while the server was still delaying or writing.
+type Request struct {
+	Method    string
+	Path      string
+	Query     string
+	Header    http.Header
+	Body      []byte
+	Received  time.Time
+	Duration  time.Duration
+	Cancelled bool
+}
+
+// Server is an httptest.Server that misbehaves on purpose.
+type Server struct {
+	*httptest.Server
+
+	cfg Config
+
+	mu       sync.Mutex
+	rnd      *rand.Rand
+	scripts  map[string][]Step
+	requests []Request
+}
+
+func New(cfg Config) *Server {
+	s := &Server{
+		cfg:     cfg,
+		rnd:     rand.New(rand.NewSource(cfg.Seed)),
+		scripts: map[string][]Step{},
+	}
+	if s.cfg.Handler == nil {
+		s.cfg.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Script queues responses for path. Each request to path takes the next
+// step; once they run out, the path goes back to the normal behavior.
+func (s *Server) Script(path string, steps ...Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[path] = append(s.scripts[path], steps...)
+}
+
+// Requests returns every request received so far. Call Close first to be
+// sure in-flight requests have been recorded.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+type plan struct {
+	step    *Step
+	latency time.Duration
+	reset   bool
+	errCode int
+}
+
+func (s *Server) plan(path string) plan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var p plan
+	if steps := s.scripts[path]; len(steps) > 0 {
+		step := steps[0]
+		s.scripts[path] = steps[1:]
+		p.step = &step
+		p.latency = step.Latency
+		p.reset = step.Reset
+		return p
+	}
+	if s.cfg.Latency != nil {
+		p.latency = s.cfg.Latency.Next(s.rnd)
+	}
+	switch u := s.rnd.Float64(); {
+	case u < s.cfg.ResetRate:
+		p.reset = true
+	case u < s.cfg.ResetRate+s.cfg.ErrorRate:
+		p.errCode = http.StatusInternalServerError
+		if len(s.cfg.ErrorCodes) > 0 {
+			p.errCode = s.cfg.ErrorCodes[s.rnd.Intn(len(s.cfg.ErrorCodes))]
+		}
+	}
+	return p
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	rec := Request{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Query:    r.URL.RawQuery,
+		Header:   r.Header.Clone(),
+		Received: time.Now(),
+	}
+	rec.Body, _ = ioutil.ReadAll(r.Body)
+	defer func() {
+		rec.Duration = time.Since(rec.Received)
+		s.mu.Lock()
+		s.requests = append(s.requests, rec)
+		s.mu.Unlock()
+	}()
+
+	p := s.plan(r.URL.Path)
+	if p.latency > 0 {
+		t := time.NewTimer(p.latency)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			rec.Cancelled = true
+			return
+		}
+	}
+	if p.reset {
+		reset(w)
+		return
+	}
+	dw := &dripWriter{ResponseWriter: w, req: r, chunk: s.cfg.DripChunk, interval: s.cfg.DripInterval}
+	switch {
+	case p.step != nil:
+		for k, v := range p.step.Header {
+			w.Header()[k] = v
+		}
+		status := p.step.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		dw.Write([]byte(p.step.Body))
+	case p.errCode != 0:
+		http.Error(dw, http.StatusText(p.errCode), p.errCode)
+	default:
+		s.cfg.Handler.ServeHTTP(dw, r)
+	}
+	rec.Cancelled = dw.cancelled || r.Context().Err() != nil
+}
+
+// reset drops the connection with an RST instead of a clean close.
+func reset(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.SetLinger(0)
+	}
+	conn.Close()
+}
+
+type dripWriter struct {
+	http.ResponseWriter
+	req       *http.Request
+	chunk     int
+	interval  time.Duration
+	cancelled bool
+}
+
+func (dw *dripWriter) Write(b []byte) (int, error) {
+	if dw.chunk <= 0 || dw.interval <= 0 {
+		return dw.ResponseWriter.Write(b)
+	}
+	written := 0
+	for len(b) > 0 {
+		n := dw.chunk
+		if n > len(b) {
+			n = len(b)
+		}
+		m, err := dw.ResponseWriter.Write(b[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		if f, ok := dw.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+		b = b[n:]
+		if len(b) == 0 {
+			break
+		}
+		t := time.NewTimer(dw.interval)
+		select {
+		case <-t.C:
+		case <-dw.req.Context().Done():
+			t.Stop()
+			dw.cancelled = true
+			return written, dw.req.Context().Err()
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,121 @@
+package faultserver
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, ctx context.Context, url string, header http.Header) (*http.Response, string, error) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp, string(body), err
+}
+
+func TestScript(t *testing.T) {
+	s := New(Config{})
+	s.Script("/orders",
+		Step{Status: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"1"}}},
+		Step{Body: "second"},
+	)
+	want := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusServiceUnavailable, ""},
+		{http.StatusOK, "second"},
+		{http.StatusOK, "ok"},
+	}
+	for i, w := range want {
+		resp, body, err := get(t, context.Background(), s.URL+"/orders", http.Header{"X-Guid": {"abc"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != w.status || body != w.body {
+			t.Errorf("request %d: expected %d `%s`, got %d `%s`", i, w.status, w.body, resp.StatusCode, body)
+		}
+	}
+	s.Close()
+	reqs := s.Requests()
+	if len(reqs) != 3 || reqs[0].Header.Get("X-GUID") != "abc" || reqs[0].Path != "/orders" {
+		t.Errorf("unexpected recorded requests %+v", reqs)
+	}
+}
+
+func TestErrorRate(t *testing.T) {
+	s := New(Config{ErrorRate: 1, ErrorCodes: []int{http.StatusBadGateway}})
+	defer s.Close()
+	resp, _, err := get(t, context.Background(), s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected 502, got %d", resp.StatusCode)
+	}
+}
+
+func TestReset(t *testing.T) {
+	s := New(Config{ResetRate: 1})
+	defer s.Close()
+	if _, _, err := get(t, context.Background(), s.URL, nil); err == nil {
+		t.Error("expected a connection error")
+	}
+}
+
+func TestCancellationRecorded(t *testing.T) {
+	s := New(Config{Latency: Fixed(2 * time.Second)})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := get(t, ctx, s.URL, nil); err == nil {
+		t.Fatal("expected the client to time out")
+	}
+	s.Close()
+	reqs := s.Requests()
+	if len(reqs) != 1 || !reqs[0].Cancelled || reqs[0].Duration >= 2*time.Second {
+		t.Errorf("expected one cancelled request, got %+v", reqs)
+	}
+}
+
+func TestDrip(t *testing.T) {
+	s := New(Config{DripChunk: 2, DripInterval: 10 * time.Millisecond})
+	s.Script("/", Step{Body: "abcdef"})
+	defer s.Close()
+	start := time.Now()
+	_, body, err := get(t, context.Background(), s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "abcdef" || time.Since(start) < 20*time.Millisecond {
+		t.Errorf("expected `abcdef` dripped over 2 pauses, got `%s` in %v", body, time.Since(start))
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	l := Percentiles(Quantile{1, time.Second}, Quantile{0.5, 10 * time.Millisecond})
+	r := rand.New(rand.NewSource(1))
+	under := 0
+	for i := 0; i < 1000; i++ {
+		d := l.Next(r)
+		if d > time.Second {
+			t.Fatalf("sample %v above p100", d)
+		}
+		if d <= 10*time.Millisecond {
+			under++
+		}
+	}
+	if under < 450 || under > 550 {
+		t.Errorf("expected about half the samples under p50, got %d/1000", under)
+	}
+}
@@ -0,0 +1,67 @@
+package faultserver
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Latency picks how long the server waits before answering a request.
+type Latency interface {
+	Next(r *rand.Rand) time.Duration
+}
+
+type fixed time.Duration
+
+func (f fixed) Next(*rand.Rand) time.Duration { return time.Duration(f) }
+
+// Fixed delays every response by d.
+func Fixed(d time.Duration) Latency {
+	return fixed(d)
+}
+
+type uniform struct {
+	min, max time.Duration
+}
+
+func (u uniform) Next(r *rand.Rand) time.Duration {
+	if u.max <= u.min {
+		return u.min
+	}
+	return u.min + time.Duration(r.Int63n(int64(u.max-u.min)))
+}
+
+// Uniform delays responses by a duration drawn evenly from [min, max).
+func Uniform(min, max time.Duration) Latency {
+	return uniform{min, max}
+}
+
+// Quantile says that a fraction P of responses take at most D.
+type Quantile struct {
+	P float64
+	D time.Duration
+}
+
+type percentiles []Quantile
+
+func (ps percentiles) Next(r *rand.Rand) time.Duration {
+	u := r.Float64()
+	prev := Quantile{}
+	for _, q := range ps {
+		if u <= q.P {
+			frac := (u - prev.P) / (q.P - prev.P)
+			return prev.D + time.Duration(frac*float64(q.D-prev.D))
+		}
+		prev = q
+	}
+	return prev.D
+}
+
+// Percentiles delays responses to match a latency profile such as
+// p50=20ms, p99=300ms, p100=1s, interpolating linearly between the given
+// points.
+func Percentiles(qs ...Quantile) Latency {
+	ps := append(percentiles(nil), qs...)
+	sort.Slice(ps, func(i, j int) bool { return ps[i].P < ps[j].P })
+	return ps
+}
@@ -0,0 +1,225 @@
+package graceful
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/fanout"
+)
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+type Config struct {
+	Addr    string
+	Handler http.Handler
+	// Listener, if set, is used instead of listening on Addr.
+	Listener net.Listener
+
+	// Zero timeouts get the values from the library example: 30s read,
+	// 90s write, 120s idle.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// DrainDelay is how long to keep accepting connections after
+	// readiness starts failing, so load balancers stop sending traffic
+	// first.
+	DrainDelay time.Duration
+	// ShutdownTimeout bounds how long in-flight requests may drain. When
+	// it runs out, the base context is cancelled and the remaining
+	// connections are closed. Zero means 30s.
+	ShutdownTimeout time.Duration
+	// HookTimeout bounds each shutdown hook. Zero means 10s.
+	HookTimeout time.Duration
+
+	// Signals that start a shutdown. Nil means SIGINT and SIGTERM.
+	Signals []os.Signal
+}
+
+type state int32
+
+const (
+	starting state = iota
+	serving
+	draining
+	stopped
+)
+
+type hook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// Server runs an http.Server until a signal arrives, then drains it and
+// runs its shutdown hooks in the order they were added. Every request's
+// context derives from a base context that is cancelled if draining runs
+// out of time, and always once the server has stopped.
+type Server struct {
+	cfg   Config
+	state int32
+
+	base       context.Context
+	cancelBase context.CancelFunc
+	stop       chan struct{}
+	stopOnce   sync.Once
+
+	mu    sync.Mutex
+	hooks []hook
+}
+
+func New(cfg Config) *Server {
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = 30 * time.Second
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = 90 * time.Second
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = 120 * time.Second
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 30 * time.Second
+	}
+	if cfg.HookTimeout == 0 {
+		cfg.HookTimeout = 10 * time.Second
+	}
+	if cfg.Signals == nil {
+		cfg.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	base, cancel := context.WithCancel(context.Background())
+	return &Server{cfg: cfg, base: base, cancelBase: cancel, stop: make(chan struct{})}
+}
+
+// OnShutdown adds a hook to run after the server has stopped, such as
+// flushing logs or closing the database.
+func (s *Server) OnShutdown(name string, fn func(context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook{name, fn})
+}
+
+// Shutdown starts the same shutdown a signal would. It does not wait;
+// Run returns once shutdown is complete.
+func (s *Server) Shutdown() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+func (s *Server) setState(st state) {
+	atomic.StoreInt32(&s.state, int32(st))
+}
+
+func (s *Server) getState() state {
+	return state(atomic.LoadInt32(&s.state))
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(LivenessPath, func(rw http.ResponseWriter, req *http.Request) {
+		if s.getState() == stopped {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte("ok"))
+	})
+	mux.HandleFunc(ReadinessPath, func(rw http.ResponseWriter, req *http.Request) {
+		if s.getState() != serving {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte("ok"))
+	})
+	if s.cfg.Handler != nil {
+		mux.Handle("/", s.cfg.Handler)
+	}
+	return mux
+}
+
+// Run serves until a configured signal arrives, ctx is done or Shutdown
+// is called, then shuts down. The hooks run however serving ends, even
+// if Serve fails. It returns the errors from serving, draining and the
+// hooks.
+func (s *Server) Run(ctx context.Context) error {
+	ln := s.cfg.Listener
+	if ln == nil {
+		var err error
+		ln, err = net.Listen("tcp", s.cfg.Addr)
+		if err != nil {
+			return err
+		}
+	}
+	srv := &http.Server{
+		Handler:      s.routes(),
+		ReadTimeout:  s.cfg.ReadTimeout,
+		WriteTimeout: s.cfg.WriteTimeout,
+		IdleTimeout:  s.cfg.IdleTimeout,
+		BaseContext:  func(net.Listener) context.Context { return s.base },
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, s.cfg.Signals...)
+	defer signal.Stop(sigs)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+	s.setState(serving)
+
+	select {
+	case err := <-serveErr:
+		s.setState(stopped)
+		s.cancelBase()
+		return s.runHooks(fanout.Errors{fmt.Errorf("serving: %w", err)})
+	case <-sigs:
+	case <-ctx.Done():
+	case <-s.stop:
+	}
+	return s.shutdown(srv)
+}
+
+func (s *Server) shutdown(srv *http.Server) error {
+	var errs fanout.Errors
+	s.setState(draining)
+	time.Sleep(s.cfg.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	err := srv.Shutdown(ctx)
+	cancel()
+	if err != nil {
+		// out of time: tell the stragglers to stop, then cut them off
+		s.cancelBase()
+		srv.Close()
+		errs = append(errs, fmt.Errorf("draining: %w", err))
+	}
+	s.cancelBase()
+	s.setState(stopped)
+	return s.runHooks(errs)
+}
+
+// runHooks runs every hook in order, adding their errors to errs.
+func (s *Server) runHooks(errs fanout.Errors) error {
+	s.mu.Lock()
+	hooks := append([]hook(nil), s.hooks...)
+	s.mu.Unlock()
+	for _, h := range hooks {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.HookTimeout)
+		if err := h.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+		cancel()
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func start(t *testing.T, cfg Config) (*Server, string, chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Listener = ln
+	s := New(cfg)
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+	return s, "http://" + ln.Addr().String(), done
+}
+
+func status(url string) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestDrainAndHooks(t *testing.T) {
+	started := make(chan struct{})
+	s, url, done := start(t, Config{
+		DrainDelay: 100 * time.Millisecond,
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			rw.Write([]byte("finished"))
+		}),
+	})
+	var order []string
+	s.OnShutdown("flush logs", func(ctx context.Context) error {
+		order = append(order, "flush logs")
+		return nil
+	})
+	s.OnShutdown("close db", func(ctx context.Context) error {
+		order = append(order, "close db")
+		return errors.New("already closed")
+	})
+	for status(url+ReadinessPath) != http.StatusOK {
+		time.Sleep(time.Millisecond)
+	}
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/work")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+	s.Shutdown()
+	time.Sleep(20 * time.Millisecond)
+	if got := status(url + ReadinessPath); got != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to fail while draining, got %d", got)
+	}
+	if got := status(url + LivenessPath); got != http.StatusOK {
+		t.Errorf("expected liveness to pass while draining, got %d", got)
+	}
+
+	if got := <-body; got != "finished" {
+		t.Errorf("expected the in-flight request to finish, got `%s`", got)
+	}
+	err := <-done
+	if err == nil || !strings.Contains(err.Error(), "close db: already closed") {
+		t.Errorf("expected the hook error, got %v", err)
+	}
+	if strings.Join(order, ",") != "flush logs,close db" {
+		t.Errorf("expected hooks in order, got %v", order)
+	}
+}
+
+func TestDrainTimeoutCancelsBase(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	s, url, done := start(t, Config{
+		ShutdownTimeout: 50 * time.Millisecond,
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			close(started)
+			<-req.Context().Done()
+			close(cancelled)
+		}),
+	})
+	go http.Get(url + "/stuck")
+	<-started
+	s.Shutdown()
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the request context to be cancelled")
+	}
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "draining") {
+		t.Errorf("expected a draining error, got %v", err)
+	}
+}
+
+// failingListener fails as soon as Serve accepts on it.
+type failingListener struct{ net.Listener }
+
+func (failingListener) Accept() (net.Conn, error) { return nil, errors.New("listener broke") }
+
+func TestServeErrorRunsHooks(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	s := New(Config{Listener: failingListener{ln}})
+	var ran bool
+	s.OnShutdown("close db", func(ctx context.Context) error {
+		ran = true
+		return errors.New("already closed")
+	})
+	err = s.Run(context.Background())
+	if !ran {
+		t.Error("expected the hook to run after Serve failed")
+	}
+	if err == nil || !strings.Contains(err.Error(), "serving: listener broke") || !strings.Contains(err.Error(), "close db: already closed") {
+		t.Errorf("expected both the serve and hook errors, got %v", err)
+	}
+}
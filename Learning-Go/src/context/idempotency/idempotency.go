@@ -0,0 +1,138 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+)
+
+const (
+	Header = "Idempotency-Key"
+	// ReplayedHeader marks a response served from the store.
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// MaxBodyBytes caps the request bodies read to fingerprint a request.
+var MaxBodyBytes int64 = 1 << 20
+
+// Middleware makes POST, PUT, PATCH and DELETE requests that carry an
+// Idempotency-Key safe to retry. Keys are scoped to the user in the
+// context. The first response for a key is stored and replayed for later
+// duplicates. A duplicate that arrives while the original is in flight
+// gets a 409, and reusing a key with a different body gets a 422.
+// Server errors and panics release the key instead, so a retry is
+// handled afresh.
+func Middleware(store Store) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			idemKey := req.Header.Get(Header)
+			if idemKey == "" || !unsafeMethod(req.Method) {
+				h.ServeHTTP(rw, req)
+				return
+			}
+			body, err := ioutil.ReadAll(io.LimitReader(req.Body, MaxBodyBytes+1))
+			req.Body.Close()
+			if err != nil {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > MaxBodyBytes {
+				rw.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			user, _ := identity.UserFromContext(req.Context())
+			key := user + "\x00" + idemKey
+			fp := fingerprint(req, body)
+			claim, entry, err := store.Begin(key, fp)
+			if err != nil {
+				log.Printf("idempotency: begin %q: %v", idemKey, err)
+				rw.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if entry != nil {
+				switch {
+				case entry.Fingerprint != fp:
+					http.Error(rw, "Idempotency-Key reused with a different request", http.StatusUnprocessableEntity)
+				case !entry.Done:
+					http.Error(rw, "request with this Idempotency-Key is still in progress", http.StatusConflict)
+				default:
+					replay(rw, entry.Response)
+				}
+				return
+			}
+
+			rec := &recorder{ResponseWriter: rw, status: http.StatusOK}
+			completed := false
+			defer func() {
+				if !completed {
+					store.Abort(key, claim)
+				}
+			}()
+			h.ServeHTTP(rec, req)
+			if rec.status >= 500 {
+				return
+			}
+			if err := store.Complete(key, claim, Response{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}); err != nil {
+				log.Printf("idempotency: complete %q: %v", idemKey, err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+func unsafeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(rw http.ResponseWriter, resp Response) {
+	for k, v := range resp.Header {
+		rw.Header()[k] = v
+	}
+	rw.Header().Set(ReplayedHeader, "true")
+	rw.WriteHeader(resp.Status)
+	rw.Write(resp.Body)
+}
+
+// recorder passes the response through while keeping a copy to store.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.header == nil {
+		r.status = code
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.header == nil {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,150 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+)
+
+func post(h http.Handler, user, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	if user != "" {
+		req = req.WithContext(identity.ContextWithUser(req.Context(), user))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReplay(t *testing.T) {
+	var calls int32
+	h := Middleware(NewMemoryStore(time.Hour))(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		rw.Header().Set("Location", "/orders/1")
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte(strings.Repeat("x", int(n))))
+	}))
+
+	first := post(h, "fred", "k1", `{"item":"bolt"}`)
+	second := post(h, "fred", "k1", `{"item":"bolt"}`)
+	if calls != 1 {
+		t.Errorf("expected one call, got %d", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() ||
+		second.Header().Get("Location") != "/orders/1" || second.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("expected a replay of the first response, got %d %v %q", second.Code, second.Header(), second.Body)
+	}
+	if rec := post(h, "fred", "k1", `{"item":"nut"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a different body, got %d", rec.Code)
+	}
+	if rec := post(h, "wilma", "k1", `{"item":"bolt"}`); rec.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("expected keys to be scoped per user, got %d after %d calls", rec.Code, calls)
+	}
+	post(h, "fred", "", `{"item":"bolt"}`)
+	if calls != 3 {
+		t.Errorf("expected requests without a key to pass through, got %d calls", calls)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	h := Middleware(NewMemoryStore(time.Hour))(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		close(entered)
+		<-release
+	}))
+	done := make(chan struct{})
+	go func() {
+		post(h, "fred", "k1", "{}")
+		close(done)
+	}()
+	<-entered
+	if rec := post(h, "fred", "k1", "{}"); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 while in flight, got %d", rec.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestServerErrorReleasesKey(t *testing.T) {
+	var calls int32
+	h := Middleware(NewMemoryStore(time.Hour))(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	post(h, "fred", "k1", "{}")
+	if rec := post(h, "fred", "k1", "{}"); rec.Code != http.StatusOK || calls != 2 {
+		t.Errorf("expected the retry to be handled, got %d after %d calls", rec.Code, calls)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s := NewMemoryStore(time.Minute)
+	now := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	claim, _, _ := s.Begin("k", "fp")
+	s.Complete("k", claim, Response{Status: http.StatusOK})
+	if _, e, _ := s.Begin("k", "fp"); e == nil || !e.Done {
+		t.Fatalf("expected a stored entry, got %+v", e)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, e, _ := s.Begin("k", "fp"); e != nil {
+		t.Errorf("expected the entry to have expired, got %+v", e)
+	}
+}
+
+func TestSlowRequestKeepsItsClaim(t *testing.T) {
+	s := NewMemoryStore(time.Minute)
+	now := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	var calls int32
+	var retry *httptest.ResponseRecorder
+	var h http.Handler
+	h = Middleware(s)(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// Still running well past the TTL when the client retries.
+			now = now.Add(10 * time.Minute)
+			retry = post(h, "fred", "k1", "{}")
+		}
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	if rec := post(h, "fred", "k1", "{}"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	if retry.Code != http.StatusConflict || calls != 1 {
+		t.Errorf("expected the retry to get a 409 without running, got %d after %d calls", retry.Code, calls)
+	}
+	now = now.Add(30 * time.Second)
+	if rec := post(h, "fred", "k1", "{}"); rec.Code != http.StatusCreated || rec.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("expected a replay within the TTL of completion, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestStaleClaim(t *testing.T) {
+	s := NewMemoryStore(time.Minute)
+	now := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	old, _, _ := s.Begin("k", "fp")
+	s.Abort("k", old)
+	claim, _, _ := s.Begin("k", "fp")
+	if err := s.Complete("k", old, Response{Status: http.StatusTeapot}); err != ErrNotFound {
+		t.Errorf("expected a stale claim to be refused, got %v", err)
+	}
+	s.Abort("k", old)
+	if _, e, _ := s.Begin("k", "fp"); e == nil || e.Done {
+		t.Fatalf("expected the live claim to survive, got %+v", e)
+	}
+	s.Complete("k", claim, Response{Status: http.StatusOK})
+	if _, e, _ := s.Begin("k", "fp"); e == nil || e.Response.Status != http.StatusOK {
+		t.Errorf("expected the live claim's response, got %+v", e)
+	}
+}
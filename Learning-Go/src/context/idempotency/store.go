@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Response is a stored response, replayed for duplicate requests.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Entry is the state of one idempotency key.
+type Entry struct {
+	// Fingerprint identifies the request body the key was first used
+	// with.
+	Fingerprint string
+	// Done is false while the first request is still being handled.
+	Done     bool
+	Response Response
+}
+
+// Store records idempotency keys. Implementations must make Begin atomic,
+// so that only one of several concurrent requests with the same key wins.
+type Store interface {
+	// Begin claims key for a request with fingerprint. If the key is
+	// new, Begin records it as in flight and returns a claim token that
+	// identifies this request. Otherwise it returns the existing entry.
+	Begin(key, fingerprint string) (claim string, existing *Entry, err error)
+	// Complete stores the response for key if claim still holds it.
+	Complete(key, claim string, resp Response) error
+	// Abort releases key, if claim still holds it, without storing
+	// anything, so the request can be retried.
+	Abort(key, claim string) error
+}
+
+// ErrNotFound is returned by Complete for a key that is not held by the
+// claim given.
+var ErrNotFound = errors.New("idempotency key not found")
+
+type memoryEntry struct {
+	Entry
+	claim   string
+	expires time.Time
+}
+
+// MemoryStore keeps completed entries in memory for ttl after they are
+// completed. Entries still in flight never expire, so a slow request
+// can't lose its key to a retry.
+type MemoryStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	claims    uint64
+	lastSweep time.Time
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, now: time.Now, entries: map[string]*memoryEntry{}}
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return e.Done && now.After(e.expires)
+}
+
+func (m *MemoryStore) Begin(key, fingerprint string) (string, *Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.Sub(m.lastSweep) >= m.ttl {
+		for k, e := range m.entries {
+			if e.expired(now) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	if e, ok := m.entries[key]; ok && !e.expired(now) {
+		out := e.Entry
+		return "", &out, nil
+	}
+	m.claims++
+	claim := strconv.FormatUint(m.claims, 10)
+	m.entries[key] = &memoryEntry{Entry: Entry{Fingerprint: fingerprint}, claim: claim}
+	return claim, nil, nil
+}
+
+func (m *MemoryStore) Complete(key, claim string, resp Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok || e.claim != claim || e.Done {
+		return ErrNotFound
+	}
+	e.Done = true
+	e.Response = resp
+	e.expires = m.now().Add(m.ttl)
+	return nil
+}
+
+func (m *MemoryStore) Abort(key, claim string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok && e.claim == claim && !e.Done {
+		delete(m.entries, key)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package identity
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/ctxdebug"
+)
+
+var userKey = ctxdebug.NewKey("identity.user", "")
+
+func ContextWithUser(ctx context.Context, user string) context.Context {
+	return userKey.WithValue(ctx, user)
+}
+
+func UserFromContext(ctx context.Context) (string, bool) {
+	user, ok := userKey.Value(ctx).(string)
+	return user, ok
+}
+
+// Middleware puts the user from a verified identity cookie into the
+// request context. Requests without a valid cookie get a 401 and never
+// reach h.
+func Middleware(codec *CookieCodec) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			user, err := codec.extractUser(req)
+			if err != nil {
+				log.Printf("identity: rejected %s %s: %s", req.Method, req.URL.Path, rejectReason(err))
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			ctx := req.Context()
+			ctx = ContextWithUser(ctx, user)
+			req = req.WithContext(ctx)
+			h.ServeHTTP(rw, req)
+		})
+	}
+}
+
+func rejectReason(err error) string {
+	switch err {
+	case http.ErrNoCookie:
+		return "missing"
+	case ErrMalformed:
+		return "malformed"
+	case ErrTampered:
+		return "tampered"
+	case ErrExpired:
+		return "expired"
+	case ErrUnknownKey:
+		return "unknown_key"
+	}
+	return err.Error()
+}
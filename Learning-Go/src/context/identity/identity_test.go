@@ -0,0 +1,105 @@
+package identity
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldKey = Key{"k1", bytes.Repeat([]byte("a"), 32)}
+	newKey = Key{"k2", bytes.Repeat([]byte("b"), 32)}
+)
+
+func newTestCodec(t *testing.T, now time.Time, keys ...Key) *CookieCodec {
+	c, err := NewCookieCodec(time.Hour, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.now = func() time.Time { return now }
+	return c
+}
+
+func TestCookieCodec(t *testing.T) {
+	now := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	signer := newTestCodec(t, now, oldKey)
+	valid := signer.Encode("fred").Value
+
+	rotated := newTestCodec(t, now, newKey, oldKey)
+	dropped := newTestCodec(t, now, newKey)
+	later := newTestCodec(t, now.Add(2*time.Hour), oldKey)
+
+	parts := strings.Split(valid, ".")
+	parts[2] = "YWRtaW4" // "admin"
+	tampered := strings.Join(parts, ".")
+
+	data := []struct {
+		name  string
+		codec *CookieCodec
+		value string
+		user  string
+		err   error
+	}{
+		{"valid", signer, valid, "fred", nil},
+		{"rotated_still_verifies", rotated, valid, "fred", nil},
+		{"new_key_signs", signer, rotated.Encode("wilma").Value, "", ErrUnknownKey},
+		{"dropped_key", dropped, valid, "", ErrUnknownKey},
+		{"tampered", signer, tampered, "", ErrTampered},
+		{"expired", later, valid, "", ErrExpired},
+		{"malformed", signer, "fred", "", ErrMalformed},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			user, err := d.codec.Decode(d.value)
+			if user != d.user || err != d.err {
+				t.Errorf("expected (%q, %v), got (%q, %v)", d.user, d.err, user, err)
+			}
+		})
+	}
+}
+
+func TestCookieCodecLiteral(t *testing.T) {
+	c := &CookieCodec{Name: "user", Keys: []Key{oldKey}, MaxAge: time.Hour}
+	user, err := c.Decode(c.Encode("fred").Value)
+	if user != "fred" || err != nil {
+		t.Errorf("expected a codec without now to use the real clock, got (%q, %v)", user, err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	now := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	codec := newTestCodec(t, now, oldKey)
+	var logs bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&logs)
+	defer log.SetOutput(prev)
+
+	var seen string
+	h := Middleware(codec)(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		seen, _ = UserFromContext(req.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(codec.Encode("fred"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || seen != "fred" {
+		t.Errorf("expected fred to be let through, got %d %q", rec.Code, seen)
+	}
+
+	seen = ""
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "user", Value: "fred"})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || seen != "" {
+		t.Errorf("expected unsigned cookie to be rejected, got %d %q", rec.Code, seen)
+	}
+	if !strings.Contains(logs.String(), "malformed") {
+		t.Errorf("expected rejection reason in log, got %q", logs.String())
+	}
+}
@@ -0,0 +1,286 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/tracker"
+)
+
+// Level is how severe an entry is. The zero Level is LevelInfo, so a
+// Config or Adapter that doesn't set one leaves debug entries out.
+type Level int
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+type Format int
+
+const (
+	JSON Format = iota
+	Logfmt
+)
+
+type Config struct {
+	Format Format
+	// Level is the least severe level written. Zero means LevelInfo.
+	Level Level
+	// PackageLevels overrides Level for loggers created with Named. A
+	// name matches its own entry or the nearest parent, so "context"
+	// also covers "context/remote".
+	PackageLevels map[string]Level
+	// DebugSampleEvery, when above 1, keeps only the first and then every
+	// Nth debug entry with the same message.
+	DebugSampleEvery int
+}
+
+// Logger writes leveled entries with key/value fields. Each entry also
+// carries the GUID, trace and user found in its context.
+type Logger struct {
+	out    *output
+	cfg    *Config
+	name   string
+	level  Level
+	fields []interface{}
+}
+
+type output struct {
+	mu      sync.Mutex
+	w       io.Writer
+	samples map[string]int
+	now     func() time.Time
+}
+
+func New(w io.Writer, cfg Config) *Logger {
+	return &Logger{
+		out:   &output{w: w, samples: map[string]int{}, now: time.Now},
+		cfg:   &cfg,
+		level: cfg.Level,
+	}
+}
+
+// Named returns a logger for a package. Its entries carry a "pkg" field
+// and it uses that package's level override, if any.
+func (l *Logger) Named(name string) *Logger {
+	nl := *l
+	nl.name = name
+	nl.level = l.cfg.levelFor(name)
+	return &nl
+}
+
+func (c *Config) levelFor(name string) Level {
+	for n := name; n != ""; {
+		if lvl, ok := c.PackageLevels[n]; ok {
+			return lvl
+		}
+		i := strings.LastIndexByte(n, '/')
+		if i == -1 {
+			break
+		}
+		n = n[:i]
+	}
+	return c.Level
+}
+
+// With returns a logger that adds kv to every entry.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	nl := *l
+	nl.fields = append(append([]interface{}{}, l.fields...), kv...)
+	return &nl
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debug(ctx context.Context, msg string, kv ...interface{}) {
+	l.log(ctx, LevelDebug, msg, kv)
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, kv ...interface{}) {
+	l.log(ctx, LevelInfo, msg, kv)
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, kv ...interface{}) {
+	l.log(ctx, LevelWarn, msg, kv)
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, kv ...interface{}) {
+	l.log(ctx, LevelError, msg, kv)
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+func (l *Logger) log(ctx context.Context, level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	if level == LevelDebug && !l.out.sample(l.name+"\x00"+msg, l.cfg.DebugSampleEvery) {
+		return
+	}
+	fields := []field{
+		{"time", l.out.now().UTC().Format(time.RFC3339Nano)},
+		{"level", level.String()},
+	}
+	if l.name != "" {
+		fields = append(fields, field{"pkg", l.name})
+	}
+	fields = append(fields, field{"msg", msg})
+	if ctx != nil {
+		if guid, ok := tracker.GUIDFromContext(ctx); ok {
+			fields = append(fields, field{"guid", guid})
+		}
+		if sc, ok := tracker.SpanContextFromContext(ctx); ok && sc.IsValid() {
+			fields = append(fields, field{"trace_id", sc.TraceID.String()}, field{"span_id", sc.SpanID.String()})
+		}
+		if user, ok := identity.UserFromContext(ctx); ok {
+			fields = append(fields, field{"user", user})
+		}
+	}
+	fields = appendPairs(fields, l.fields)
+	fields = appendPairs(fields, kv)
+
+	var buf bytes.Buffer
+	if l.cfg.Format == Logfmt {
+		encodeLogfmt(&buf, fields)
+	} else {
+		encodeJSON(&buf, fields)
+	}
+	buf.WriteByte('\n')
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(buf.Bytes())
+}
+
+func (o *output) sample(key string, every int) bool {
+	if every <= 1 {
+		return true
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// a fresh map every so often keeps per-message counters bounded
+	if len(o.samples) > 1024 {
+		o.samples = map[string]int{}
+	}
+	n := o.samples[key]
+	o.samples[key] = n + 1
+	return n%every == 0
+}
+
+func appendPairs(fields []field, kv []interface{}) []field {
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 == len(kv) {
+			fields = append(fields, field{"!BADKEY", kv[i]})
+			break
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		fields = append(fields, field{key, kv[i+1]})
+	}
+	return fields
+}
+
+func encodeJSON(buf *bytes.Buffer, fields []field) {
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v := f.value
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			b, _ = json.Marshal(fmt.Sprint(v))
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+}
+
+func encodeLogfmt(buf *bytes.Buffer, fields []field) {
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(f.key)
+		buf.WriteByte('=')
+		var s string
+		switch v := f.value.(type) {
+		case string:
+			s = v
+		case error:
+			s = v.Error()
+		case nil:
+			s = "null"
+		default:
+			s = fmt.Sprint(v)
+		}
+		if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+			s = strconv.Quote(s)
+		}
+		buf.WriteString(s)
+	}
+}
+
+// Adapter lets a Logger stand in wherever the single-method
+// Log(context.Context, string) interface is expected, such as
+// BusinessLogic.Logger.
+type Adapter struct {
+	Logger *Logger
+	// Level is the level messages are logged at. Zero means LevelInfo.
+	Level Level
+}
+
+func (a Adapter) Log(ctx context.Context, message string) {
+	a.Logger.log(ctx, a.Level, message, nil)
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/tracker"
+)
+
+func newTestLogger(cfg Config) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	l := New(&buf, cfg)
+	l.out.now = func() time.Time { return time.Date(2021, 6, 6, 13, 2, 50, 0, time.UTC) }
+	return l, &buf
+}
+
+func TestJSONFromContext(t *testing.T) {
+	l, buf := newTestLogger(Config{Level: LevelInfo})
+	var ctx context.Context
+	h := tracker.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx = identity.ContextWithUser(req.Context(), "fred")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(tracker.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	span, _ := tracker.SpanFromContext(ctx)
+
+	l.Named("remote").Info(ctx, "called remote", "status", 200, "err", errors.New("boom"))
+	want := `{"time":"2021-06-06T13:02:50Z","level":"info","pkg":"remote","msg":"called remote",` +
+		`"guid":"4bf92f3577b34da6a3ce929d0e0e4736","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736",` +
+		`"span_id":"` + span.SpanID + `","user":"fred","status":200,"err":"boom"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestLogfmt(t *testing.T) {
+	l, buf := newTestLogger(Config{Format: Logfmt})
+	l.With("service", "orders").Warn(context.Background(), "slow query", "query", "select 1", "empty", "", "dangling")
+	want := `time=2021-06-06T13:02:50Z level=warn msg="slow query" service=orders query="select 1" empty="" !BADKEY=dangling` + "\n"
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestPackageLevels(t *testing.T) {
+	l, buf := newTestLogger(Config{
+		Format: Logfmt,
+		Level:  LevelWarn,
+		PackageLevels: map[string]Level{
+			"context":       LevelDebug,
+			"context/noisy": LevelError,
+		},
+	})
+	ctx := context.Background()
+	l.Info(ctx, "root info")
+	l.Named("context/remote").Debug(ctx, "remote debug")
+	l.Named("context/noisy").Warn(ctx, "noisy warn")
+	l.Named("context/noisy/deeper").Error(ctx, "noisy error")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "remote debug") || !strings.Contains(lines[1], "noisy error") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestDebugSampling(t *testing.T) {
+	l, buf := newTestLogger(Config{Format: Logfmt, Level: LevelDebug, DebugSampleEvery: 3})
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		l.Debug(ctx, "tick")
+		l.Info(ctx, "info")
+	}
+	if got := strings.Count(buf.String(), "msg=tick"); got != 3 {
+		t.Errorf("expected 3 sampled debug entries, got %d", got)
+	}
+	if got := strings.Count(buf.String(), "msg=info"); got != 7 {
+		t.Errorf("expected every info entry, got %d", got)
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	l, buf := newTestLogger(Config{Format: Logfmt})
+	var logger interface {
+		Log(context.Context, string)
+	} = Adapter{Logger: l, Level: LevelError}
+	logger.Log(identity.ContextWithUser(context.Background(), "fred"), "failed")
+	want := "time=2021-06-06T13:02:50Z level=error msg=failed user=fred\n"
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestAdapterDefaultsToInfo(t *testing.T) {
+	l, buf := newTestLogger(Config{Format: Logfmt, DebugSampleEvery: 2})
+	a := Adapter{Logger: l}
+	for i := 0; i < 2; i++ {
+		a.Log(context.Background(), "in business logic")
+	}
+	want := "time=2021-06-06T13:02:50Z level=info msg=\"in business logic\"\n"
+	if buf.String() != want+want {
+		t.Errorf("expected both entries at info, got\n%s", buf.String())
+	}
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+)
+
+// Limiter throttles each user, or each client IP for anonymous requests.
+type Limiter struct {
+	Store Store
+	// Default applies to anonymous requests and to users with no role in
+	// RoleQuotas.
+	Default    Quota
+	RoleQuotas map[string]Quota
+	// Roles lists a user's roles, e.g. authz.Authorizer.Roles. A user
+	// with several limited roles gets the most generous quota.
+	Roles func(user string) []string
+	// Now is the clock; nil means time.Now.
+	Now func() time.Time
+}
+
+func (l *Limiter) quota(user string) Quota {
+	q, found := l.Default, false
+	if l.Roles == nil {
+		return q
+	}
+	for _, r := range l.Roles(user) {
+		rq, ok := l.RoleQuotas[r]
+		if ok && (!found || rq.Rate > q.Rate || (rq.Rate == q.Rate && rq.Burst > q.Burst)) {
+			q, found = rq, true
+		}
+	}
+	return q
+}
+
+// Middleware sets RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset on every response, and answers 429 with Retry-After
+// once a bucket is empty. It must run after identity.Middleware to see
+// users. It panics if a quota has no Rate.
+func (l *Limiter) Middleware(h http.Handler) http.Handler {
+	if l.Default.Rate <= 0 {
+		panic("ratelimit: Default quota needs a positive Rate")
+	}
+	for role, q := range l.RoleQuotas {
+		if q.Rate <= 0 {
+			panic(fmt.Sprintf("ratelimit: quota for role %q needs a positive Rate", role))
+		}
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		now := time.Now
+		if l.Now != nil {
+			now = l.Now
+		}
+		var key string
+		q := l.Default
+		if user, ok := identity.UserFromContext(req.Context()); ok {
+			key = "user:" + user
+			q = l.quota(user)
+		} else {
+			key = "ip:" + clientIP(req)
+		}
+		r := l.Store.Take(key, q, now())
+		rw.Header().Set("RateLimit-Limit", strconv.Itoa(r.Limit))
+		rw.Header().Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+		rw.Header().Set("RateLimit-Reset", ceilSeconds(r.Reset))
+		if !r.Allowed {
+			rw.Header().Set("Retry-After", ceilSeconds(r.RetryAfter))
+			rw.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(rw, req)
+	})
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
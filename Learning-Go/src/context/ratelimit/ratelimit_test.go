@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/identity"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter(clock *fakeClock) *Limiter {
+	return &Limiter{
+		Store:   NewMemoryStore(time.Minute),
+		Default: Quota{Rate: 1, Burst: 2},
+		RoleQuotas: map[string]Quota{
+			"premium": {Rate: 10, Burst: 5},
+		},
+		Roles: func(user string) []string {
+			if user == "wilma" {
+				return []string{"viewer", "premium"}
+			}
+			return nil
+		},
+		Now: clock.Now,
+	}
+}
+
+func serve(h http.Handler, user, addr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = addr
+	if user != "" {
+		req = req.WithContext(identity.ContextWithUser(req.Context(), user))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)}
+	h := newTestLimiter(clock).Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	data := []struct {
+		name      string
+		advance   time.Duration
+		code      int
+		remaining string
+		reset     string
+		retry     string
+	}{
+		{"first", 0, http.StatusOK, "1", "1", ""},
+		{"second", 0, http.StatusOK, "0", "2", ""},
+		{"empty", 0, http.StatusTooManyRequests, "0", "2", "1"},
+		{"half_refilled", 500 * time.Millisecond, http.StatusTooManyRequests, "0", "2", "1"},
+		{"refilled", 500 * time.Millisecond, http.StatusOK, "0", "2", ""},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			clock.Advance(d.advance)
+			rec := serve(h, "fred", "10.0.0.1:1234")
+			if rec.Code != d.code {
+				t.Errorf("expected %d, got %d", d.code, rec.Code)
+			}
+			hdr := rec.Header()
+			if hdr.Get("RateLimit-Limit") != "2" || hdr.Get("RateLimit-Remaining") != d.remaining ||
+				hdr.Get("RateLimit-Reset") != d.reset || hdr.Get("Retry-After") != d.retry {
+				t.Errorf("unexpected headers %v", hdr)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)}
+	h := newTestLimiter(clock).Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	for i := 0; i < 2; i++ {
+		serve(h, "", "10.0.0.1:1234")
+	}
+	if rec := serve(h, "", "10.0.0.1:5678"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected anonymous requests from one IP to share a bucket, got %d", rec.Code)
+	}
+	if rec := serve(h, "", "10.0.0.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("expected another IP to have its own bucket, got %d", rec.Code)
+	}
+	if rec := serve(h, "fred", "10.0.0.1:1234"); rec.Code != http.StatusOK {
+		t.Errorf("expected a user on a throttled IP to have their own bucket, got %d", rec.Code)
+	}
+	if rec := serve(h, "wilma", "10.0.0.1:1234"); rec.Header().Get("RateLimit-Limit") != "5" {
+		t.Errorf("expected the premium quota for wilma, got %v", rec.Header())
+	}
+}
+
+func TestEviction(t *testing.T) {
+	s := NewMemoryStore(time.Minute)
+	start := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	q := Quota{Rate: 1, Burst: 1}
+	s.Take("a", q, start)
+	s.Take("b", q, start.Add(30*time.Second))
+	s.Take("c", q, start.Add(90*time.Second))
+	if s.Len() != 2 {
+		t.Errorf("expected the idle bucket to be evicted, have %d", s.Len())
+	}
+}
+
+func TestEvictionKeepsDrainedBuckets(t *testing.T) {
+	start := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	// Takes 10s to refill, longer than either idle timeout.
+	q := Quota{Rate: 1, Burst: 10}
+	for _, idle := range []time.Duration{time.Second, 2 * time.Second} {
+		t.Run(idle.String(), func(t *testing.T) {
+			s := NewMemoryStore(idle)
+			for i := 0; i < 10; i++ {
+				s.Take("a", q, start)
+			}
+			s.Take("b", q, start.Add(2*time.Second))
+			if r := s.Take("a", q, start.Add(2*time.Second)); r.Remaining != 1 {
+				t.Errorf("expected a to have refilled 2 tokens and spent 1, got %d left", r.Remaining)
+			}
+			s.Take("b", q, start.Add(30*time.Second))
+			if s.Len() != 1 {
+				t.Errorf("expected a to be evicted once full, have %d", s.Len())
+			}
+		})
+	}
+}
+
+func TestZeroRateBucketsAreEvicted(t *testing.T) {
+	s := NewMemoryStore(time.Minute)
+	start := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	q := Quota{Burst: 1}
+	s.Take("a", q, start)
+	r := s.Take("a", q, start)
+	if r.Allowed || r.RetryAfter != maxWait || r.Reset != maxWait {
+		t.Errorf("expected a capped wait for a bucket that never refills, got %+v", r)
+	}
+	s.Take("b", q, start.Add(2*time.Minute))
+	if s.Len() != 1 {
+		t.Errorf("expected the idle bucket to be evicted, have %d", s.Len())
+	}
+}
+
+func TestWaitsAreCapped(t *testing.T) {
+	s := NewMemoryStore(time.Minute)
+	start := time.Date(2021, 6, 6, 13, 0, 0, 0, time.UTC)
+	q := Quota{Rate: 1e-12, Burst: 1}
+	s.Take("a", q, start)
+	if r := s.Take("a", q, start); r.RetryAfter != maxWait || r.Reset != maxWait {
+		t.Errorf("expected waits capped at %v, got %+v", maxWait, r)
+	}
+}
+
+func TestDefaultIdle(t *testing.T) {
+	if s := NewMemoryStore(0); s.idle != time.Minute {
+		t.Errorf("expected a minute, got %v", s.idle)
+	}
+}
+
+func TestZeroRateQuotaPanics(t *testing.T) {
+	data := []struct {
+		name string
+		l    *Limiter
+	}{
+		{"default", &Limiter{Default: Quota{Burst: 5}}},
+		{"role", &Limiter{Default: Quota{Rate: 1, Burst: 5}, RoleQuotas: map[string]Quota{"admin": {Burst: 5}}}},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a quota with no Rate to panic")
+				}
+			}()
+			d.l.Middleware(http.NotFoundHandler())
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Quota is a token bucket: Burst requests at once, refilled at Rate per
+// second. Rate must be positive.
+type Quota struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, when not Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets. MemoryStore suits a single instance; a shared
+// backend can implement Store to limit across several.
+type Store interface {
+	Take(key string, q Quota, now time.Time) Result
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	quota  Quota
+}
+
+// refilled returns how many tokens b holds at now.
+func (b *bucket) refilled(now time.Time) float64 {
+	tokens := b.tokens
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(b.quota.Burst), tokens+elapsed*b.quota.Rate)
+	}
+	return tokens
+}
+
+// MemoryStore keeps buckets in a map and drops those unused for longer
+// than the idle timeout, but only once they have refilled: a new bucket
+// starts full, so dropping a full one doesn't change any answer. A
+// bucket whose quota has no Rate never refills, so it is dropped once
+// idle regardless, which keeps memory bounded at the cost of a fresh
+// Burst.
+type MemoryStore struct {
+	idle time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns a MemoryStore that sweeps idle buckets every
+// idle. Zero or less means a minute.
+func NewMemoryStore(idle time.Duration) *MemoryStore {
+	if idle <= 0 {
+		idle = time.Minute
+	}
+	return &MemoryStore{idle: idle, buckets: map[string]*bucket{}}
+}
+
+func (m *MemoryStore) Take(key string, q Quota, now time.Time) Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) >= m.idle {
+		m.sweep(now)
+	}
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(q.Burst), last: now}
+		m.buckets[key] = b
+	}
+	b.quota = q
+	b.tokens = b.refilled(now)
+	b.last = now
+	r := Result{Limit: q.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = secondsFor(1-b.tokens, q.Rate)
+	}
+	r.Remaining = int(b.tokens)
+	r.Reset = secondsFor(float64(q.Burst)-b.tokens, q.Rate)
+	return r
+}
+
+// maxWait caps the waits reported, so a tiny Rate can't overflow a
+// Duration or promise a reset centuries away.
+const maxWait = 365 * 24 * time.Hour
+
+func secondsFor(tokens, rate float64) time.Duration {
+	if rate <= 0 || tokens/rate > maxWait.Seconds() {
+		return maxWait
+	}
+	return time.Duration(tokens / rate * float64(time.Second))
+}
+
+func (m *MemoryStore) sweep(now time.Time) {
+	for k, b := range m.buckets {
+		full := b.quota.Rate <= 0 || b.refilled(now) >= float64(b.quota.Burst)
+		if now.Sub(b.last) > m.idle && full {
+			delete(m.buckets, k)
+		}
+	}
+	m.lastSweep = now
+}
+
+// Len reports how many buckets are held.
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
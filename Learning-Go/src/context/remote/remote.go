@@ -0,0 +1,152 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type Logger interface {
+	Log(context.Context, string)
+}
+
+var (
+	ErrBadRequest   = errors.New("remote rejected the request")
+	ErrUnauthorized = errors.New("remote refused access")
+	ErrNotFound     = errors.New("remote resource not found")
+	ErrUnavailable  = errors.New("remote unavailable")
+)
+
+// StatusError is returned for non-2xx responses. It matches one of the
+// Err values above with errors.Is, depending on the status code.
+type StatusError struct {
+	StatusCode int
+	// Body is the start of the response body, for diagnostics.
+	Body string
+}
+
+func (se *StatusError) Error() string {
+	msg := fmt.Sprintf("remote returned %d %s", se.StatusCode, http.StatusText(se.StatusCode))
+	if se.Body != "" {
+		msg += ": " + se.Body
+	}
+	return msg
+}
+
+func (se *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return se.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return se.StatusCode == http.StatusUnauthorized || se.StatusCode == http.StatusForbidden
+	case ErrUnavailable:
+		return se.StatusCode >= 500 || se.StatusCode == http.StatusTooManyRequests
+	case ErrBadRequest:
+		return se.StatusCode >= 400 && se.StatusCode < 500
+	}
+	return false
+}
+
+// DecodeError is returned when a 2xx response body isn't the expected
+// JSON.
+type DecodeError struct {
+	Err error
+}
+
+func (de *DecodeError) Error() string {
+	return "decoding remote response: " + de.Err.Error()
+}
+
+func (de *DecodeError) Unwrap() error {
+	return de.Err
+}
+
+// Client queries a remote service that answers GET BaseURL?query=... with
+// JSON.
+type Client struct {
+	BaseURL string
+	// HTTP sends the requests. Nil means http.DefaultClient.
+	HTTP *http.Client
+	// Decorate, if set, is applied to each request before it is sent.
+	Decorate func(*http.Request) *http.Request
+	// Logger, if set, gets one line per request. Loggers such as
+	// tracker.Logger add the request's GUID from ctx.
+	Logger Logger
+}
+
+const maxErrorBody = 512
+
+// Query sends query and decodes the JSON response into out, which must
+// be a pointer. out is only written if the whole response decodes; a 204
+// or an empty body leaves it alone and returns nil.
+func (c Client) Query(ctx context.Context, query string, out interface{}) error {
+	if v := reflect.ValueOf(out); v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("remote: Query needs a non-nil pointer, not %T", out)
+	}
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("query", query)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		c.log(ctx, "error building remote request: "+err.Error())
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Decorate != nil {
+		req = c.Decorate(req)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		c.log(ctx, fmt.Sprintf("remote request failed after %v: %v", time.Since(start), err))
+		return err
+	}
+	defer resp.Body.Close()
+	c.log(ctx, fmt.Sprintf("remote returned %d in %v", resp.StatusCode, time.Since(start)))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	fresh := reflect.New(reflect.TypeOf(out).Elem())
+	dec := json.NewDecoder(resp.Body)
+	if err := dec.Decode(fresh.Interface()); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		return &DecodeError{Err: err}
+	}
+	if dec.More() {
+		return &DecodeError{Err: errors.New("unexpected data after JSON value")}
+	}
+	reflect.ValueOf(out).Elem().Set(fresh.Elem())
+	return nil
+}
+
+func (c Client) log(ctx context.Context, msg string) {
+	if c.Logger != nil {
+		c.Logger.Log(ctx, msg)
+	}
+}
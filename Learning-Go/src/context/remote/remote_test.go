@@ -0,0 +1,145 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/faultserver"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/tracker"
+)
+
+type result struct {
+	Result string `json:"result"`
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (rl *recordingLogger) Log(ctx context.Context, msg string) {
+	if guid, ok := tracker.GUIDFromContext(ctx); ok {
+		msg = guid + " " + msg
+	}
+	rl.lines = append(rl.lines, msg)
+}
+
+func TestQuery(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		gotQuery = req.URL.Query().Get("query")
+		switch gotQuery {
+		case "missing":
+			http.Error(rw, "no such thing", http.StatusNotFound)
+		case "busy":
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		case "forbidden":
+			rw.WriteHeader(http.StatusForbidden)
+		case "malformed":
+			rw.Write([]byte(`{"result": `))
+		case "no_content":
+			rw.WriteHeader(http.StatusNoContent)
+		case "empty":
+		case "trailing":
+			rw.Write([]byte(`{"result": "a"} {"result": "b"}`))
+		default:
+			rw.Write([]byte(`{"result": "ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	data := []struct {
+		name   string
+		query  string
+		result string
+		target error
+		errMsg string
+	}{
+		{"escaped", "a b&c=d?#e", "ok", nil, ""},
+		{"not_found", "missing", "", ErrNotFound, "remote returned 404 Not Found: no such thing"},
+		{"unavailable", "busy", "", ErrUnavailable, "remote returned 503 Service Unavailable"},
+		{"forbidden", "forbidden", "", ErrUnauthorized, "remote returned 403 Forbidden"},
+		{"malformed", "malformed", "", nil, "decoding remote response: unexpected EOF"},
+		{"trailing", "trailing", "", nil, "decoding remote response: unexpected data after JSON value"},
+		{"no_content", "no_content", "", nil, ""},
+		{"empty", "empty", "", nil, ""},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			c := Client{BaseURL: server.URL + "/query", HTTP: server.Client()}
+			var r result
+			err := c.Query(context.Background(), d.query, &r)
+			if gotQuery != d.query {
+				t.Errorf("expected server to see query `%s`, got `%s`", d.query, gotQuery)
+			}
+			if r.Result != d.result {
+				t.Errorf("expected result `%s`, got `%s`", d.result, r.Result)
+			}
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != d.errMsg {
+				t.Errorf("expected error `%s`, got `%s`", d.errMsg, errMsg)
+			}
+			if d.target != nil && !errors.Is(err, d.target) {
+				t.Errorf("expected errors.Is(%v, %v)", err, d.target)
+			}
+		})
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	server := faultserver.New(faultserver.Config{Latency: faultserver.Fixed(time.Second)})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := Client{BaseURL: server.URL, HTTP: server.Client()}
+	var r result
+	err := c.Query(ctx, "slow", &r)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+	server.Close()
+	if reqs := server.Requests(); len(reqs) != 1 || !reqs[0].Cancelled {
+		t.Errorf("expected the server to see the request cancelled, got %+v", reqs)
+	}
+}
+
+func TestQuerySlowBody(t *testing.T) {
+	server := faultserver.New(faultserver.Config{DripChunk: 4, DripInterval: 200 * time.Millisecond})
+	server.Script("/", faultserver.Step{Body: `{"result": "eventually"}`})
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	c := Client{BaseURL: server.URL, HTTP: server.Client()}
+	var r result
+	if err := c.Query(ctx, "drip", &r); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to cut off a slow body, got %v", err)
+	}
+}
+
+func TestQueryLogsGUID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"result": "ok"}`))
+	}))
+	defer server.Close()
+	var ctx context.Context
+	incoming := httptest.NewRequest(http.MethodGet, "/", nil)
+	incoming.Header.Set(tracker.GUIDHeader, "guid-1")
+	tracker.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx = req.Context()
+	})).ServeHTTP(httptest.NewRecorder(), incoming)
+	logger := &recordingLogger{}
+	c := Client{BaseURL: server.URL, HTTP: server.Client(), Logger: logger, Decorate: tracker.Request}
+	var r result
+	if err := c.Query(ctx, "q", &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.lines) != 1 || !strings.HasPrefix(logger.lines[0], "guid-1 remote returned 200") {
+		t.Errorf("unexpected log lines %q", logger.lines)
+	}
+}
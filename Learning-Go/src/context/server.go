@@ -2,27 +2,28 @@ package main
 
 import (
 	"net/http"
-	"net/http/httptest"
 	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/faultserver"
 )
 
-func slowServer() *httptest.Server {
-	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
-		r *http.Request) {
-		time.Sleep(2 * time.Second)
-		w.Write([]byte("Slow response"))
-	}))
-	return s
+func slowServer() *faultserver.Server {
+	return faultserver.New(faultserver.Config{
+		Latency: faultserver.Fixed(2 * time.Second),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Slow response"))
+		}),
+	})
 }
 
-func fastServer() *httptest.Server {
-	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
-		r *http.Request) {
-		if r.URL.Query().Get("error") == "true" {
-			w.Write([]byte("error"))
-			return
-		}
-		w.Write([]byte("ok"))
-	}))
-	return s
+func fastServer() *faultserver.Server {
+	return faultserver.New(faultserver.Config{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("error") == "true" {
+				w.Write([]byte("error"))
+				return
+			}
+			w.Write([]byte("ok"))
+		}),
+	})
 }
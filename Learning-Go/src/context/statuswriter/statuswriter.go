@@ -0,0 +1,92 @@
+// Package statuswriter records the status code a handler sends, for
+// middleware that logs or counts responses.
+package statuswriter
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Writer remembers the status code a handler sent.
+type Writer struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+// Wrap returns a Writer around rw, and rw wrapped to implement
+// http.Flusher and http.Hijacker exactly when rw does, so handlers that
+// type-assert for them keep working. Pass the second to the handler.
+func Wrap(rw http.ResponseWriter) (*Writer, http.ResponseWriter) {
+	w := &Writer{ResponseWriter: rw, status: http.StatusOK}
+	_, canFlush := rw.(http.Flusher)
+	_, canHijack := rw.(http.Hijacker)
+	switch {
+	case canFlush && canHijack:
+		return w, flushHijackWriter{w}
+	case canFlush:
+		return w, flushWriter{w}
+	case canHijack:
+		return w, hijackWriter{w}
+	}
+	return w, w
+}
+
+// Status is the code sent: 200 if the handler wrote without one, and 101
+// if it hijacked the connection.
+func (w *Writer) Status() int {
+	return w.status
+}
+
+// Wrote reports whether anything has been sent yet.
+func (w *Writer) Wrote() bool {
+	return w.wrote
+}
+
+func (w *Writer) WriteHeader(code int) {
+	if !w.wrote {
+		w.status = code
+		w.wrote = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the original writer.
+func (w *Writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *Writer) flush() {
+	w.wrote = true
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *Writer) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil && !w.wrote {
+		w.status = http.StatusSwitchingProtocols
+		w.wrote = true
+	}
+	return conn, rw, err
+}
+
+type flushWriter struct{ *Writer }
+
+func (w flushWriter) Flush() { w.flush() }
+
+type hijackWriter struct{ *Writer }
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type flushHijackWriter struct{ *Writer }
+
+func (w flushHijackWriter) Flush() { w.flush() }
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
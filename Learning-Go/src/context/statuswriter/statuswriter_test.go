@@ -0,0 +1,66 @@
+package statuswriter
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestWrap(t *testing.T) {
+	data := []struct {
+		name      string
+		rw        http.ResponseWriter
+		canFlush  bool
+		canHijack bool
+		status    int
+	}{
+		{"flusher", httptest.NewRecorder(), true, false, http.StatusOK},
+		{"both", hijackRecorder{httptest.NewRecorder()}, true, true, http.StatusSwitchingProtocols},
+		{"hijacker", struct {
+			http.ResponseWriter
+			http.Hijacker
+		}{httptest.NewRecorder(), hijackRecorder{}}, false, true, http.StatusSwitchingProtocols},
+		{"neither", struct{ http.ResponseWriter }{httptest.NewRecorder()}, false, false, http.StatusOK},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			sw, rw := Wrap(d.rw)
+			f, canFlush := rw.(http.Flusher)
+			hj, canHijack := rw.(http.Hijacker)
+			if canFlush != d.canFlush || canHijack != d.canHijack {
+				t.Errorf("expected flush %v hijack %v, got %v %v", d.canFlush, d.canHijack, canFlush, canHijack)
+			}
+			if canHijack {
+				hj.Hijack()
+			} else if canFlush {
+				f.Flush()
+			}
+			if sw.Status() != d.status || sw.Wrote() != (canFlush || canHijack) {
+				t.Errorf("expected status %d, got %d (wrote %v)", d.status, sw.Status(), sw.Wrote())
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	sw, rw := Wrap(httptest.NewRecorder())
+	if sw.Wrote() {
+		t.Error("expected nothing written yet")
+	}
+	rw.WriteHeader(http.StatusTeapot)
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte("short and stout"))
+	if sw.Status() != http.StatusTeapot {
+		t.Errorf("expected the first status, got %d", sw.Status())
+	}
+}
@@ -0,0 +1,68 @@
+package tracker
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Exporter receives every sampled span once it has finished.
+type Exporter interface {
+	Export(*Span) error
+}
+
+// JSONLinesExporter appends each span to a file as one JSON object per
+// line.
+type JSONLinesExporter struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+func NewJSONLinesExporter(path string) (*JSONLinesExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLinesExporter{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (e *JSONLinesExporter) Export(s *Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(s)
+}
+
+func (e *JSONLinesExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.f.Close()
+}
+
+// Recorder keeps finished spans in memory so tests can inspect them.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (r *Recorder) Export(s *Span) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+	return nil
+}
+
+// Spans returns the recorded spans in the order they finished.
+func (r *Recorder) Spans() []*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*Span, len(r.spans))
+	copy(out, r.spans)
+	return out
+}
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
@@ -0,0 +1,169 @@
+package tracker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/ctxdebug"
+)
+
+// Span is one timed operation within a trace. Its exported fields are
+// what exporters write out; they must not be modified once End is called.
+type Span struct {
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Duration   time.Duration     `json:"duration_ns"`
+	Status     int               `json:"status,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	sc     SpanContext
+	tracer *Tracer
+	mu     sync.Mutex
+	ended  bool
+}
+
+const (
+	KindInternal = "internal"
+	KindServer   = "server"
+	KindClient   = "client"
+)
+
+// SpanContext returns the identifiers to propagate to children of s.
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+func (s *Span) String() string {
+	return s.Name + " " + s.sc.Traceparent()
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if s.Attributes == nil {
+		s.Attributes = map[string]string{}
+	}
+	s.Attributes[key] = value
+}
+
+// SetStatus records the HTTP status code the span finished with.
+func (s *Span) SetStatus(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.Status = code
+	}
+}
+
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.Error = err.Error()
+	}
+}
+
+// Finish stamps the end time and hands the span to the tracer's
+// exporters. Calls after the first are ignored.
+func (s *Span) Finish() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.Duration = s.End.Sub(s.Start)
+	s.mu.Unlock()
+	if s.sc.Sampled() {
+		s.tracer.export(s)
+	}
+}
+
+// Tracer creates spans and sends the finished ones to its exporters.
+type Tracer struct {
+	Exporters []Exporter
+	// OnError is called when an exporter fails. Export errors never reach
+	// the request being traced.
+	OnError func(error)
+}
+
+func NewTracer(exporters ...Exporter) *Tracer {
+	return &Tracer{Exporters: exporters}
+}
+
+// DefaultTracer backs the package-level Middleware. It has no exporters,
+// so it only propagates IDs until one is added.
+var DefaultTracer = NewTracer()
+
+func (t *Tracer) export(s *Span) {
+	for _, e := range t.Exporters {
+		if err := e.Export(s); err != nil && t.OnError != nil {
+			t.OnError(err)
+		}
+	}
+}
+
+var (
+	currentSpan  = ctxdebug.NewKey("tracker.span", (*Span)(nil))
+	remoteParent = ctxdebug.NewKey("tracker.remote_parent", SpanContext{})
+)
+
+// Start begins a span that is a child of the span in ctx, or of the
+// remote parent extracted by Middleware. With neither, it starts a new,
+// sampled trace.
+func (t *Tracer) Start(ctx context.Context, name string, kind string) (context.Context, *Span) {
+	s := &Span{
+		Name:   name,
+		Kind:   kind,
+		Start:  time.Now(),
+		tracer: t,
+	}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		s.sc = parent
+		// A parent known only by its X-GUID has a trace but no span.
+		if parent.SpanID.IsValid() {
+			s.ParentID = parent.SpanID.String()
+		}
+	} else {
+		s.sc = SpanContext{TraceID: newTraceID(), Flags: flagSampled}
+	}
+	s.sc.SpanID = newSpanID()
+	s.TraceID = s.sc.TraceID.String()
+	s.SpanID = s.sc.SpanID.String()
+	return currentSpan.WithValue(ctx, s), s
+}
+
+// SpanFromContext returns the span currently active in ctx.
+func SpanFromContext(ctx context.Context) (*Span, bool) {
+	s, ok := currentSpan.Value(ctx).(*Span)
+	return s, ok
+}
+
+// SpanContextFromContext returns the span context new children of ctx
+// should use as their parent: the active span if there is one, otherwise
+// the remote parent that arrived with the request.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if s, ok := SpanFromContext(ctx); ok {
+		return s.sc, true
+	}
+	sc, ok := remoteParent.Value(ctx).(SpanContext)
+	return sc, ok
+}
+
+func contextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return remoteParent.WithValue(ctx, sc)
+}
@@ -0,0 +1,208 @@
+package tracker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TraceID and SpanID are the identifiers carried in a W3C traceparent
+// header. See https://www.w3.org/TR/trace-context/.
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+
+func newTraceID() TraceID {
+	var t TraceID
+	rand.Read(t[:])
+	return t
+}
+
+func newSpanID() SpanID {
+	var s SpanID
+	rand.Read(s[:])
+	return s
+}
+
+const flagSampled = 0x01
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+	State   TraceState
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+func (sc SpanContext) String() string { return sc.Traceparent() }
+
+// Traceparent formats sc as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+var errTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses a traceparent header value. Versions above 00
+// are accepted as long as their first four fields look like version 00,
+// as the spec requires.
+func ParseTraceparent(v string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || !isLowerHex(parts[0]) || parts[0] == "ff" {
+		return sc, errTraceparent
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, errTraceparent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 ||
+		!isLowerHex(parts[1]) || !isLowerHex(parts[2]) || !isLowerHex(parts[3]) {
+		return sc, errTraceparent
+	}
+	hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	var flags [1]byte
+	hex.Decode(flags[:], []byte(parts[3]))
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, errTraceparent
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// traceIDFromGUID turns a legacy X-GUID (normally a UUID) into a trace ID
+// so old callers still end up in a single trace.
+func traceIDFromGUID(guid string) (TraceID, bool) {
+	var t TraceID
+	h := strings.ToLower(strings.Replace(guid, "-", "", -1))
+	if len(h) != 32 || !isLowerHex(h) {
+		return t, false
+	}
+	hex.Decode(t[:], []byte(h))
+	return t, t.IsValid()
+}
+
+// TraceState is the ordered list of vendor entries from a tracestate
+// header. The most recently updated entry comes first.
+type TraceState []TraceStateMember
+
+type TraceStateMember struct {
+	Key   string
+	Value string
+}
+
+const maxTraceStateMembers = 32
+
+// ParseTraceState parses a tracestate header value. Malformed entries make
+// the whole header invalid, per the spec; the caller should then drop it.
+func ParseTraceState(v string) (TraceState, error) {
+	var ts TraceState
+	seen := map[string]bool{}
+	for _, m := range strings.Split(v, ",") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		eq := strings.IndexByte(m, '=')
+		if eq <= 0 || eq == len(m)-1 {
+			return nil, fmt.Errorf("invalid tracestate member %q", m)
+		}
+		key, value := m[:eq], m[eq+1:]
+		if !validStateKey(key) || !validStateValue(value) {
+			return nil, fmt.Errorf("invalid tracestate member %q", m)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate tracestate key %q", key)
+		}
+		seen[key] = true
+		ts = append(ts, TraceStateMember{key, value})
+	}
+	if len(ts) > maxTraceStateMembers {
+		return nil, errors.New("too many tracestate members")
+	}
+	return ts, nil
+}
+
+func validStateKey(k string) bool {
+	if len(k) > 256 {
+		return false
+	}
+	for _, c := range k {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '_', c == '-', c == '*', c == '/', c == '@':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func validStateValue(v string) bool {
+	if len(v) > 256 || strings.HasSuffix(v, " ") {
+		return false
+	}
+	for _, c := range v {
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the value stored under key.
+func (ts TraceState) Get(key string) (string, bool) {
+	for _, m := range ts {
+		if m.Key == key {
+			return m.Value, true
+		}
+	}
+	return "", false
+}
+
+// Insert returns a copy of ts with key set to value and moved to the
+// front, which is how a service records that it touched the trace.
+func (ts TraceState) Insert(key, value string) (TraceState, error) {
+	if !validStateKey(key) || !validStateValue(value) {
+		return ts, fmt.Errorf("invalid tracestate member %q=%q", key, value)
+	}
+	out := TraceState{{key, value}}
+	for _, m := range ts {
+		if m.Key != key && len(out) < maxTraceStateMembers {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (ts TraceState) String() string {
+	parts := make([]string, 0, len(ts))
+	for _, m := range ts {
+		parts = append(parts, m.Key+"="+m.Value)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,126 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/ctxdebug"
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/statuswriter"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+	// GUIDHeader is the pre-trace-context correlation header. It is still
+	// read and written so older services stay correlated.
+	GUIDHeader = "X-GUID"
+)
+
+var guidKey = ctxdebug.NewKey("tracker.guid", "")
+
+func contextWithGUID(ctx context.Context, guid string) context.Context {
+	return guidKey.WithValue(ctx, guid)
+}
+
+// GUIDFromContext returns the request's correlation ID: the caller's
+// X-GUID if it sent one, otherwise the trace ID.
+func GUIDFromContext(ctx context.Context) (string, bool) {
+	g, ok := guidKey.Value(ctx).(string)
+	return g, ok
+}
+
+// Middleware traces requests with DefaultTracer.
+func Middleware(h http.Handler) http.Handler {
+	return DefaultTracer.Middleware(h)
+}
+
+// Middleware starts a server span for every request, parented by the
+// incoming traceparent header, or by the legacy X-GUID when that is all
+// the caller sent.
+func (t *Tracer) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		guid := req.Header.Get(GUIDHeader)
+		if sc, err := ParseTraceparent(req.Header.Get(TraceparentHeader)); err == nil {
+			if ts, err := ParseTraceState(req.Header.Get(TracestateHeader)); err == nil {
+				sc.State = ts
+			}
+			ctx = contextWithRemoteParent(ctx, sc)
+		} else if id, ok := traceIDFromGUID(guid); ok {
+			ctx = contextWithRemoteParent(ctx, SpanContext{TraceID: id, Flags: flagSampled})
+		}
+		ctx, span := t.Start(ctx, req.Method+" "+req.URL.Path, KindServer)
+		defer span.Finish()
+		if guid == "" {
+			guid = span.TraceID
+		}
+		ctx = contextWithGUID(ctx, guid)
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("http.target", req.URL.RequestURI())
+		sw, wrapped := statuswriter.Wrap(rw)
+		h.ServeHTTP(wrapped, req.WithContext(ctx))
+		span.SetStatus(sw.Status())
+	})
+}
+
+type Logger struct{}
+
+func (Logger) Log(ctx context.Context, message string) {
+	if guid, ok := GUIDFromContext(ctx); ok {
+		message = fmt.Sprintf("GUID: %s - %s", guid, message)
+	}
+	// do logging
+	fmt.Println(message)
+}
+
+// Request copies the trace context in req's context onto its headers. It
+// is a RequestDecorator for clients that don't use Transport, so it does
+// not create a client span of its own.
+func Request(req *http.Request) *http.Request {
+	inject(req.Context(), req.Header)
+	return req
+}
+
+func inject(ctx context.Context, h http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok && sc.IsValid() {
+		h.Set(TraceparentHeader, sc.Traceparent())
+		if len(sc.State) > 0 {
+			h.Set(TracestateHeader, sc.State.String())
+		}
+	}
+	if guid, ok := GUIDFromContext(ctx); ok {
+		h.Set(GUIDHeader, guid)
+	}
+}
+
+// Transport is an http.RoundTripper that records a client span for each
+// request and propagates it to the server. The span ends when the
+// response headers arrive.
+type Transport struct {
+	Base   http.RoundTripper
+	Tracer *Tracer
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tracer, base := t.Tracer, t.Base
+	if tracer == nil {
+		tracer = DefaultTracer
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx, span := tracer.Start(req.Context(), req.Method+" "+req.URL.Host, KindClient)
+	defer span.Finish()
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.String())
+	req = req.Clone(ctx)
+	inject(ctx, req.Header)
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetStatus(resp.StatusCode)
+	return resp, nil
+}
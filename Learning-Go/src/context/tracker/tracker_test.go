@@ -0,0 +1,171 @@
+package tracker
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	data := []struct {
+		name  string
+		in    string
+		valid bool
+	}{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"future_version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"extra_fields_v00", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"upper_case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"zero_trace", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"zero_span", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"version_ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"short", "00-4bf92f35-00f067aa0ba902b7-01", false},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(d.in)
+			if (err == nil) != d.valid {
+				t.Fatalf("expected valid=%v, got err %v", d.valid, err)
+			}
+			if d.valid && d.in[:2] == "00" && sc.Traceparent() != d.in {
+				t.Errorf("expected round trip `%s`, got `%s`", d.in, sc.Traceparent())
+			}
+		})
+	}
+}
+
+func TestTraceState(t *testing.T) {
+	ts, err := ParseTraceState("rojo=00f067aa0ba902b7, congo=t61rcWkgMzE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err = ts.Insert("congo", "ucfJifl5GOE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.String(); got != "congo=ucfJifl5GOE,rojo=00f067aa0ba902b7" {
+		t.Errorf("unexpected tracestate `%s`", got)
+	}
+	if _, err := ParseTraceState("rojo=1,rojo=2"); err == nil {
+		t.Error("expected error for duplicate key")
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	rec := &Recorder{}
+	tracer := NewTracer(rec)
+	var downstream *http.Request
+	back := httptest.NewServer(tracer.Middleware(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			downstream = req
+			rw.WriteHeader(http.StatusTeapot)
+		})))
+	defer back.Close()
+	client := &http.Client{Transport: Transport{Tracer: tracer}}
+	front := httptest.NewServer(tracer.Middleware(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			out, _ := http.NewRequestWithContext(req.Context(), http.MethodGet, back.URL, nil)
+			resp, err := client.Do(out)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		})))
+	defer front.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, front.URL+"/orders", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(TracestateHeader, "rojo=00f067aa0ba902b7")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := rec.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	backSpan, clientSpan, frontSpan := spans[0], spans[1], spans[2]
+	for _, s := range spans {
+		if s.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("span %s has trace %s", s.Name, s.TraceID)
+		}
+	}
+	if frontSpan.ParentID != "00f067aa0ba902b7" || frontSpan.Name != "GET /orders" {
+		t.Errorf("unexpected front span %+v", frontSpan)
+	}
+	if clientSpan.ParentID != frontSpan.SpanID || clientSpan.Status != http.StatusTeapot {
+		t.Errorf("unexpected client span %+v", clientSpan)
+	}
+	if backSpan.ParentID != clientSpan.SpanID || backSpan.Status != http.StatusTeapot {
+		t.Errorf("unexpected back span %+v", backSpan)
+	}
+	if got := downstream.Header.Get(TracestateHeader); got != "rojo=00f067aa0ba902b7" {
+		t.Errorf("expected tracestate to propagate, got `%s`", got)
+	}
+	if got := downstream.Header.Get(GUIDHeader); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected X-GUID to default to the trace ID, got `%s`", got)
+	}
+}
+
+func TestLegacyGUID(t *testing.T) {
+	rec := &Recorder{}
+	var guid string
+	var out *http.Request
+	h := NewTracer(rec).Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		guid, _ = GUIDFromContext(req.Context())
+		out, _ = http.NewRequestWithContext(req.Context(), http.MethodGet, "http://example.com", nil)
+		out = Request(out)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(GUIDHeader, "0b6d5b5e-36a1-4c9e-8f1a-6a8a4a3d2c10")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if guid != "0b6d5b5e-36a1-4c9e-8f1a-6a8a4a3d2c10" {
+		t.Errorf("expected X-GUID to be kept, got `%s`", guid)
+	}
+	if got := rec.Spans()[0].TraceID; got != "0b6d5b5e36a14c9e8f1a6a8a4a3d2c10" {
+		t.Errorf("expected trace ID derived from X-GUID, got `%s`", got)
+	}
+	if got := rec.Spans()[0].ParentID; got != "" {
+		t.Errorf("expected no parent span ID, got `%s`", got)
+	}
+	if out.Header.Get(GUIDHeader) != guid || out.Header.Get(TraceparentHeader) == "" {
+		t.Errorf("expected Request to set both headers, got %v", out.Header)
+	}
+	if _, ok := GUIDFromContext(context.Background()); ok {
+		t.Error("expected no GUID in a bare context")
+	}
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestMiddlewareKeepsInterfaces(t *testing.T) {
+	rec := &Recorder{}
+	var canFlush, canHijack bool
+	h := NewTracer(rec).Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, canFlush = rw.(http.Flusher)
+		var hj http.Hijacker
+		if hj, canHijack = rw.(http.Hijacker); canHijack {
+			hj.Hijack()
+		}
+	}))
+	h.ServeHTTP(hijackRecorder{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if !canFlush || !canHijack {
+		t.Errorf("expected Flusher and Hijacker, got %v and %v", canFlush, canHijack)
+	}
+	if got := rec.Spans()[0].Status; got != http.StatusSwitchingProtocols {
+		t.Errorf("expected a hijacked span to have status 101, got %d", got)
+	}
+}
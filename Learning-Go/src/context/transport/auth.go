@@ -0,0 +1,73 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Bearer sets an Authorization: Bearer header on every request.
+func Bearer(token string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+token)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// HMACHeader carries the request signature added by HMAC.
+const HMACHeader = "X-Signature"
+
+var now = time.Now
+
+// HMAC signs each request with HMAC-SHA256 over the method, the request
+// URI, a unix timestamp and the SHA-256 of the body. The header value is
+// keyID:timestamp:hex(mac); see SignatureBase for the signed string.
+func HMAC(keyID string, secret []byte) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			body, err := readBody(req)
+			if err != nil {
+				return nil, err
+			}
+			ts := strconv.FormatInt(now().Unix(), 10)
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(SignatureBase(req.Method, req.URL.RequestURI(), ts, body)))
+			req.Header.Set(HMACHeader, keyID+":"+ts+":"+hex.EncodeToString(mac.Sum(nil)))
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// SignatureBase is the string HMAC signs, exported so servers can verify.
+func SignatureBase(method, requestURI, timestamp string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return method + "\n" + requestURI + "\n" + timestamp + "\n" + hex.EncodeToString(sum[:])
+}
+
+// readBody returns req's body and leaves req with a fresh, replayable copy
+// of it.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
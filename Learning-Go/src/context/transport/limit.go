@@ -0,0 +1,50 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// HostLimit allows at most n requests in flight to each host. A request
+// holds its slot until its response body is closed, since the connection
+// is busy until then.
+func HostLimit(n int) Middleware {
+	var mu sync.Mutex
+	slots := map[string]chan struct{}{}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			sem, ok := slots[req.URL.Host]
+			if !ok {
+				sem = make(chan struct{}, n)
+				slots[req.URL.Host] = sem
+			}
+			mu.Unlock()
+			select {
+			case sem <- struct{}{}:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				<-sem
+				return nil, err
+			}
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { <-sem }}
+			return resp, nil
+		})
+	}
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (rb *releaseBody) Close() error {
+	err := rb.ReadCloser.Close()
+	rb.once.Do(rb.release)
+	return err
+}
@@ -0,0 +1,186 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/logging"
+)
+
+type LogConfig struct {
+	// Bodies turns on request and response body logging.
+	Bodies bool
+	// MaxBody caps how much of each body is logged. Zero means 1024.
+	MaxBody int
+	// Redact names the JSON and form fields whose values are replaced
+	// before logging, matched case-insensitively. Nil means
+	// DefaultRedact.
+	Redact []string
+}
+
+var DefaultRedact = []string{"password", "token", "secret", "api_key", "authorization"}
+
+const redacted = "[REDACTED]"
+
+// Log writes one entry per request with its method, URL, status and
+// duration, plus redacted bodies when LogConfig.Bodies is set. With
+// Bodies, a response's entry is written when its body is closed.
+func Log(l *logging.Logger, cfg LogConfig) Middleware {
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = 1024
+	}
+	if cfg.Redact == nil {
+		cfg.Redact = DefaultRedact
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			kv := []interface{}{"method", req.Method, "url", redactURL(req.URL, cfg.Redact)}
+			if cfg.Bodies && req.Body != nil && req.Body != http.NoBody {
+				req = req.Clone(req.Context())
+				body, err := readBody(req)
+				if err != nil {
+					return nil, err
+				}
+				kv = append(kv, "request_body", cfg.render(req.Header, body, len(body)))
+			}
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			kv = append(kv, "duration_ms", time.Since(start).Milliseconds())
+			if err != nil {
+				l.Warn(req.Context(), "http client request failed", append(kv, "err", err)...)
+				return nil, err
+			}
+			kv = append(kv, "status", resp.StatusCode)
+			if cfg.Bodies && resp.Body != nil && resp.Body != http.NoBody {
+				// Reading ahead would stall on a streamed response, so the
+				// body is logged as the caller reads it, once they close it.
+				resp.Body = &loggedBody{ReadCloser: resp.Body, done: func(prefix []byte, n int64) {
+					if resp.ContentLength > 0 {
+						n = resp.ContentLength
+					}
+					kv = append(kv, "response_body", cfg.render(resp.Header, prefix, int(n)))
+					l.Info(req.Context(), "http client request", kv...)
+				}, max: cfg.MaxBody + 1}
+				return resp, nil
+			}
+			l.Info(req.Context(), "http client request", kv...)
+			return resp, nil
+		})
+	}
+}
+
+// loggedBody keeps the first max bytes the caller reads and hands them to
+// done on Close, along with how many bytes were read in all.
+type loggedBody struct {
+	io.ReadCloser
+	max    int
+	prefix []byte
+	n      int64
+	done   func(prefix []byte, n int64)
+	once   sync.Once
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.max - len(b.prefix); room > 0 {
+		if room > n {
+			room = n
+		}
+		b.prefix = append(b.prefix, p[:room]...)
+	}
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *loggedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.prefix, b.n) })
+	return err
+}
+
+// render returns a loggable, redacted form of body. JSON that can't be
+// parsed, usually because it was cut at MaxBody, is summarized rather
+// than logged, since its secrets can't be found reliably. So is any body
+// that is neither JSON nor a form, for the same reason.
+func (cfg LogConfig) render(h http.Header, body []byte, size int) string {
+	ct := h.Get("Content-Type")
+	truncated := len(body) > cfg.MaxBody
+	switch {
+	case strings.Contains(ct, "json"):
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if truncated || dec.Decode(&v) != nil {
+			return "[" + strconv.Itoa(size) + " bytes of unparsed JSON]"
+		}
+		out, _ := json.Marshal(redactValue(v, cfg.Redact))
+		return string(out)
+	case strings.HasPrefix(ct, "application/x-www-form-urlencoded"):
+		if truncated {
+			return "[" + strconv.Itoa(size) + " bytes of form data]"
+		}
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "[" + strconv.Itoa(size) + " bytes of unparsed form data]"
+		}
+		redactForm(values, cfg.Redact)
+		return values.Encode()
+	}
+	return "[" + strconv.Itoa(size) + " bytes]"
+}
+
+func isRedacted(key string, fields []string) bool {
+	for _, f := range fields {
+		if strings.EqualFold(key, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactValue(v interface{}, fields []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, inner := range v {
+			if isRedacted(k, fields) {
+				v[k] = redacted
+			} else {
+				v[k] = redactValue(inner, fields)
+			}
+		}
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = redactValue(inner, fields)
+		}
+	}
+	return v
+}
+
+func redactForm(values url.Values, fields []string) {
+	for k := range values {
+		if isRedacted(k, fields) {
+			values[k] = []string{redacted}
+		}
+	}
+}
+
+// redactURL hides any userinfo, which the username alone may be a token
+// in, as well as redacted query parameters.
+func redactURL(u *url.URL, fields []string) string {
+	c := *u
+	if c.User != nil {
+		c.User = url.User("REDACTED")
+	}
+	if q := u.Query(); len(q) > 0 {
+		redactForm(q, fields)
+		c.RawQuery = q.Encode()
+	}
+	return c.String()
+}
@@ -0,0 +1,138 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RetryConfig struct {
+	// MaxAttempts is the total number of tries, including the first.
+	// Zero means 3.
+	MaxAttempts int
+	// BaseDelay and MaxDelay bound the exponential backoff. Zero means
+	// 100ms and 2s.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryOn reports whether an attempt should be retried. Nil means
+	// DefaultRetryOn.
+	RetryOn func(*http.Response, error) bool
+}
+
+// DefaultRetryOn retries transport errors, 429 and the 5xx codes that
+// usually mean a transient upstream problem.
+func DefaultRetryOn(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Retry retries failed attempts with jittered exponential backoff, or
+// after the server's Retry-After when that fits within MaxDelay. Only
+// idempotent methods, or requests carrying an Idempotency-Key, are
+// retried, and only if their body can be replayed.
+func Retry(cfg RetryConfig) Middleware {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 100 * time.Millisecond
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 2 * time.Second
+	}
+	if cfg.RetryOn == nil {
+		cfg.RetryOn = DefaultRetryOn
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			if !retryable(req) {
+				return next.RoundTrip(req)
+			}
+			for attempt := 1; ; attempt++ {
+				try := req
+				if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					try = req.Clone(req.Context())
+					try.Body = body
+				}
+				resp, err := next.RoundTrip(try)
+				if attempt == cfg.MaxAttempts || !cfg.RetryOn(resp, err) {
+					return resp, err
+				}
+				delay := backoff(cfg, attempt)
+				if resp != nil {
+					if ra, ok := retryAfter(resp); ok {
+						if ra > cfg.MaxDelay {
+							return resp, err
+						}
+						delay = ra
+					}
+					io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+					resp.Body.Close()
+				}
+				if err := sleep(req.Context(), delay); err != nil {
+					return nil, err
+				}
+			}
+		})
+	}
+}
+
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func backoff(cfg RetryConfig, attempt int) time.Duration {
+	d := cfg.BaseDelay << uint(attempt-1)
+	if d > cfg.MaxDelay || d <= 0 {
+		d = cfg.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/linghduoduo/GoLang/Learning-Go/src/context/tracker"
+)
+
+// Middleware wraps a RoundTripper. Unlike a RequestDecorator it sees the
+// response too, so it can retry, time or log the whole exchange.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// Func adapts a plain function to http.RoundTripper.
+type Func func(*http.Request) (*http.Response, error)
+
+func (f Func) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain is an ordered list of client middleware. The first one added is
+// the outermost: it sees the request first and the response last.
+type Chain struct {
+	mws []Middleware
+}
+
+func NewChain(mws ...Middleware) Chain {
+	return Chain{mws: append([]Middleware(nil), mws...)}
+}
+
+// Append returns a new chain with mws added inside the existing ones.
+func (c Chain) Append(mws ...Middleware) Chain {
+	out := make([]Middleware, 0, len(c.mws)+len(mws))
+	out = append(out, c.mws...)
+	return Chain{mws: append(out, mws...)}
+}
+
+// Then wraps base, or http.DefaultTransport if base is nil.
+func (c Chain) Then(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for i := len(c.mws) - 1; i >= 0; i-- {
+		base = c.mws[i](base)
+	}
+	return base
+}
+
+// Client returns an *http.Client that sends through the chain.
+func (c Chain) Client(base http.RoundTripper) *http.Client {
+	return &http.Client{Transport: c.Then(base)}
+}
+
+// Trace records a client span per request and sends the traceparent,
+// tracestate and X-GUID headers. It replaces tracker.Request.
+func Trace(t *tracker.Tracer) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return tracker.Transport{Base: next, Tracer: t}
+	}
+}
+
+// Decorate runs an existing RequestDecorator, such as deadline.Request, as
+// part of a chain. The request is cloned first so the caller's copy is
+// left untouched.
+func Decorate(d func(*http.Request) *http.Request) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			return next.RoundTrip(d(req.Clone(req.Context())))
+		})
+	}
+}
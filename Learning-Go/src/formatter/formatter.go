@@ -14,7 +14,7 @@ func Space(length int, parts ...string) string {
 	}
 	totalLen := 0
 	for _, v := range parts {
-		totalLen += len(v)
+		totalLen += displayWidth(v)
 	}
 	minSpaces := len(parts) - 1
 	if length < totalLen+minSpaces {
@@ -34,3 +34,21 @@ func Space(length int, parts ...string) string {
 	}
 	return out.String()
 }
+
+// pad fills s with spaces out to width display columns. align is one of
+// '<', '>' or '^', matching the template spec syntax.
+func pad(s string, width int, align byte) string {
+	spares := width - displayWidth(s)
+	if spares <= 0 {
+		return s
+	}
+	switch align {
+	case '>':
+		return strings.Repeat(" ", spares) + s
+	case '^':
+		left := spares / 2
+		return strings.Repeat(" ", left) + s + strings.Repeat(" ", spares-left)
+	default:
+		return s + strings.Repeat(" ", spares)
+	}
+}
@@ -0,0 +1,303 @@
+package formatter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Template is a compiled report layout such as
+//
+//	"{name:<20} {qty:>5} {price:>10.2f}"
+//
+// Each {field:spec} column takes an optional alignment (< left, > right,
+// ^ center), a minimum width in display columns, a precision and a verb
+// (s, d or f). Literal braces are written as {{ and }}. Every field and
+// spec is checked by Compile, so Render only fails when handed a row of
+// the wrong shape.
+type Template struct {
+	layout   string
+	segments []segment
+	typ      reflect.Type
+}
+
+type segment struct {
+	literal   string
+	field     string
+	index     int
+	align     byte
+	width     int
+	precision int
+	verb      byte
+}
+
+var specPattern = regexp.MustCompile(`^([<>^])?(\d+)?(?:\.(\d+))?([sdf])?$`)
+
+// Compile parses layout and resolves its fields against proto, which is
+// either a struct (or pointer to one) or a map with string keys. Struct
+// fields are matched by a `format` tag, then a `json` tag, then a
+// case-insensitive field name. For a map, the keys present in proto are
+// the known fields.
+func Compile(layout string, proto interface{}) (*Template, error) {
+	t := &Template{layout: layout}
+	v := reflect.ValueOf(proto)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct && !(v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String) {
+		return nil, fmt.Errorf("formatter: cannot compile template for %T", proto)
+	}
+	t.typ = v.Type()
+	var lit strings.Builder
+	for i := 0; i < len(layout); i++ {
+		c := layout[i]
+		switch {
+		case c == '{' && i+1 < len(layout) && layout[i+1] == '{':
+			lit.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(layout) && layout[i+1] == '}':
+			lit.WriteByte('}')
+			i++
+		case c == '}':
+			return nil, fmt.Errorf("formatter: unmatched '}' at offset %d", i)
+		case c == '{':
+			end := strings.IndexAny(layout[i+1:], "{}")
+			if end == -1 || layout[i+1+end] == '{' {
+				return nil, fmt.Errorf("formatter: unterminated field at offset %d", i)
+			}
+			if lit.Len() > 0 {
+				t.segments = append(t.segments, segment{literal: lit.String(), index: -1})
+				lit.Reset()
+			}
+			seg, err := t.parseField(v, layout[i+1:i+1+end])
+			if err != nil {
+				return nil, fmt.Errorf("formatter: field at offset %d: %w", i, err)
+			}
+			t.segments = append(t.segments, seg)
+			i += end + 1
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	if lit.Len() > 0 {
+		t.segments = append(t.segments, segment{literal: lit.String(), index: -1})
+	}
+	return t, nil
+}
+
+// MustCompile is like Compile but panics if the layout is invalid. It is
+// meant for package-level report layouts.
+func MustCompile(layout string, proto interface{}) *Template {
+	t, err := Compile(layout, proto)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *Template) parseField(proto reflect.Value, field string) (segment, error) {
+	name, spec := field, ""
+	if colon := strings.IndexByte(field, ':'); colon != -1 {
+		name, spec = field[:colon], field[colon+1:]
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return segment{}, fmt.Errorf("missing field name")
+	}
+	m := specPattern.FindStringSubmatch(spec)
+	if m == nil {
+		return segment{}, fmt.Errorf("bad spec %q for %q", spec, name)
+	}
+	seg := segment{field: name, index: -1, precision: -1}
+	if m[1] != "" {
+		seg.align = m[1][0]
+	}
+	if m[2] != "" {
+		seg.width, _ = strconv.Atoi(m[2])
+	}
+	if m[3] != "" {
+		seg.precision, _ = strconv.Atoi(m[3])
+	}
+	if m[4] != "" {
+		seg.verb = m[4][0]
+	}
+	if seg.verb == 'd' && seg.precision != -1 {
+		return segment{}, fmt.Errorf("precision not allowed with 'd' for %q", name)
+	}
+
+	var kind reflect.Kind
+	if proto.Kind() == reflect.Struct {
+		seg.index = fieldIndex(proto.Type(), name)
+		if seg.index == -1 {
+			return segment{}, fmt.Errorf("unknown field %q", name)
+		}
+		kind = proto.Type().Field(seg.index).Type.Kind()
+	} else {
+		if !proto.MapIndex(reflect.ValueOf(name).Convert(proto.Type().Key())).IsValid() {
+			return segment{}, fmt.Errorf("unknown field %q", name)
+		}
+		kind = proto.Type().Elem().Kind()
+	}
+	if kind != reflect.Interface {
+		if err := checkVerb(seg.verb, kind); err != nil {
+			return segment{}, fmt.Errorf("%q: %w", name, err)
+		}
+	}
+	if seg.align == 0 {
+		seg.align = '<'
+		if isNumber(kind) {
+			seg.align = '>'
+		}
+	}
+	return seg, nil
+}
+
+func fieldIndex(typ reflect.Type, name string) int {
+	for _, tag := range []string{"format", "json"} {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if v := strings.Split(f.Tag.Get(tag), ",")[0]; v != "" && v == name {
+				return i
+			}
+		}
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath == "" && strings.EqualFold(f.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isNumber(k reflect.Kind) bool {
+	return isInt(k) || k == reflect.Float32 || k == reflect.Float64
+}
+
+func isInt(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func checkVerb(verb byte, k reflect.Kind) error {
+	switch {
+	case verb == 'd' && !isInt(k):
+		return fmt.Errorf("verb 'd' needs an integer, got %s", k)
+	case verb == 'f' && !isNumber(k):
+		return fmt.Errorf("verb 'f' needs a number, got %s", k)
+	}
+	return nil
+}
+
+// Render formats a single row. row must have the same type as the proto
+// the template was compiled with, or be a pointer to it.
+func (t *Template) Render(row interface{}) (string, error) {
+	v := reflect.ValueOf(row)
+	for v.Kind() == reflect.Ptr && v.Type() != t.typ {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Type() != t.typ {
+		return "", fmt.Errorf("formatter: template compiled for %s, got %T", t.typ, row)
+	}
+	var out strings.Builder
+	for _, seg := range t.segments {
+		if seg.field == "" {
+			out.WriteString(seg.literal)
+			continue
+		}
+		var fv reflect.Value
+		if v.Kind() == reflect.Struct {
+			fv = v.Field(seg.index)
+		} else {
+			fv = v.MapIndex(reflect.ValueOf(seg.field).Convert(t.typ.Key()))
+			if !fv.IsValid() {
+				return "", fmt.Errorf("formatter: row is missing field %q", seg.field)
+			}
+		}
+		s, err := seg.format(fv)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+func (seg segment) format(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if err := checkVerb(seg.verb, v.Kind()); err != nil {
+		return "", fmt.Errorf("formatter: %q: %w", seg.field, err)
+	}
+	var s string
+	switch {
+	case seg.verb == 'f' || (seg.verb == 0 && seg.precision != -1 && isNumber(v.Kind())):
+		prec := seg.precision
+		if prec == -1 {
+			prec = 6
+		}
+		var f float64
+		if isInt(v.Kind()) {
+			f, _ = strconv.ParseFloat(fmt.Sprint(v.Interface()), 64)
+		} else {
+			f = v.Float()
+		}
+		s = strconv.FormatFloat(f, 'f', prec, 64)
+	case v.IsValid():
+		s = fmt.Sprint(v.Interface())
+		if seg.precision != -1 {
+			s = truncate(s, seg.precision)
+		}
+	}
+	return pad(s, seg.width, seg.align), nil
+}
+
+// Header renders the field names through the same column widths and
+// alignments as the rows, for use as a report heading.
+func (t *Template) Header() string {
+	var out strings.Builder
+	for _, seg := range t.segments {
+		if seg.field == "" {
+			out.WriteString(seg.literal)
+			continue
+		}
+		out.WriteString(pad(seg.field, seg.width, seg.align))
+	}
+	return out.String()
+}
+
+// Execute renders every element of rows, which must be a slice or array,
+// writing one line per row to w.
+func (t *Template) Execute(w io.Writer, rows interface{}) error {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Errorf("formatter: Execute needs a slice of rows, got %T", rows)
+	}
+	bw := bufio.NewWriter(w)
+	for i := 0; i < v.Len(); i++ {
+		line, err := t.Render(v.Index(i).Interface())
+		if err != nil {
+			return fmt.Errorf("row %d: %w", i, err)
+		}
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// String returns the layout the template was compiled from.
+func (t *Template) String() string {
+	return t.layout
+}
@@ -0,0 +1,94 @@
+package formatter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type lineItem struct {
+	Name  string `format:"name"`
+	Qty   int    `json:"qty"`
+	Price float64
+}
+
+func TestTemplateRender(t *testing.T) {
+	data := []struct {
+		name   string
+		layout string
+		row    interface{}
+		out    string
+	}{
+		{"struct", "{name:<8}|{qty:>5}|{price:>8.2f}", lineItem{"bolt", 12, 0.5}, "bolt    |   12|    0.50"},
+		{"pointer", "{name}|{qty}", &lineItem{"nut", 3, 1}, "nut|3"},
+		{"default_align", "{name:6}|{qty:4}", lineItem{"ab", 7, 0}, "ab    |   7"},
+		{"center", "[{name:^7}]", lineItem{Name: "mid"}, "[  mid  ]"},
+		{"truncate", "{name:.3}", lineItem{Name: "washer"}, "was"},
+		{"escaped", "{{{name}}}", lineItem{Name: "x"}, "{x}"},
+		{"wide_runes", "{name:<6}|", lineItem{Name: "日本"}, "日本  |"},
+		{"combining", "{name:<4}|", lineItem{Name: "e\u0301"}, "e\u0301   |"},
+		{"map", "{sku:<6}{qty:>3d}", map[string]interface{}{"sku": "A-1", "qty": 4}, "A-1     4"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			tmpl, err := Compile(d.layout, d.row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tmpl.Render(d.row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(d.out, out); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	data := []struct {
+		name   string
+		layout string
+		errMsg string
+	}{
+		{"unknown", "{nope}", `formatter: field at offset 0: unknown field "nope"`},
+		{"bad_spec", "{name:>x}", `formatter: field at offset 0: bad spec ">x" for "name"`},
+		{"verb_mismatch", "{name:5d}", `formatter: field at offset 0: "name": verb 'd' needs an integer, got string`},
+		{"unterminated", "ok {name", "formatter: unterminated field at offset 3"},
+		{"unmatched", "name}", "formatter: unmatched '}' at offset 4"},
+		{"empty", "{:5}", "formatter: field at offset 0: missing field name"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			_, err := Compile(d.layout, lineItem{})
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != d.errMsg {
+				t.Errorf("expected error `%s`, got `%s`", d.errMsg, errMsg)
+			}
+		})
+	}
+}
+
+func TestTemplateExecute(t *testing.T) {
+	tmpl := MustCompile("{name:<6} {qty:>3}", lineItem{})
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, []lineItem{{Name: "bolt", Qty: 12}, {Name: "nut", Qty: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "bolt    12\nnut      3\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Error(diff)
+	}
+	if _, err := tmpl.Render(map[string]interface{}{"name": "x"}); err == nil {
+		t.Error("expected error rendering a row of the wrong type")
+	}
+	if diff := cmp.Diff("name   qty", tmpl.Header()); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -1,5 +1,8 @@
 module github.com/linghduoduo/GoLang
 
-go 1.15
+go 1.20
 
-require github.com/google/go-cmp v0.5.6
+require (
+	github.com/google/go-cmp v0.5.6
+	gopkg.in/yaml.v3 v3.0.1
+)